@@ -3,7 +3,12 @@ package actionaverager
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -11,7 +16,17 @@ const (
 
 	actKey         = "action"
 	timeKey        = "time"
+	labelsKey      = "labels"
 	emptyArrayJSON = "[]"
+
+	// numShards is the number of shards actions are spread over, it is a power of 2 so a shard can be picked with
+	// a mask instead of a division
+	numShards = 64
+	// cacheLineSize is the padding between shards, so that locking one shard does not slow down its neighbours
+	cacheLineSize = 64
+
+	fnvOffset = 2166136261
+	fnvPrime  = 16777619
 )
 
 // ActionAverager averages times for actions
@@ -20,122 +35,534 @@ type ActionAverager interface {
 	GetStats() string
 }
 
-type outputJSON struct {
-	Action  string  `json:"action"`
-	Average float64 `json:"avg"`
+// SampleAverager is an ActionAverager that also accepts an action and time directly, which skips the json
+// serialization of AddAction
+type SampleAverager interface {
+	ActionAverager
+	AddSample(string, float64) error
+}
+
+// WeightedAverager is an ActionAverager that also accepts an action and time weighted as if the time had been
+// added weight times
+type WeightedAverager interface {
+	ActionAverager
+	AddWeightedSample(string, float64, float64) error
+}
+
+type sampleJSON struct {
+	Action string  `json:"action"`
+	Time   float64 `json:"time"`
 }
 
+// AddSample adds the action and time to averager with AddSample when averager is a SampleAverager, otherwise the
+// action and time are serialized to json for AddAction
+func AddSample(averager ActionAverager, actStr string, timeFlt float64) error {
+	if sampleAverager, ok := averager.(SampleAverager); ok {
+		return sampleAverager.AddSample(actStr, timeFlt)
+	}
+
+	// NOTE: json can not represent NaN and infinite times so validate them before marshaling
+	if err := validateSample(actStr, timeFlt); err != nil {
+		return err
+	}
+	// WARNING: suppressing error, since sampleJSON with a finite time is always able to be marshaled
+	input, _ := json.Marshal(&sampleJSON{Action: actStr, Time: timeFlt})
+	return averager.AddAction(string(input))
+}
+
+// ActionStats are the stats for a single action, it is a copy so it is safe to use after it is returned
+type ActionStats struct {
+	Action    string             `json:"action"`
+	Labels    map[string]string  `json:"labels,omitempty"`
+	Average   float64            `json:"avg"`
+	Count     float64            `json:"count"`
+	Sum       float64            `json:"sum"`
+	Min       float64            `json:"min"`
+	Max       float64            `json:"max"`
+	Variance  float64            `json:"variance"`
+	StdDev    float64            `json:"stddev"`
+	Quantiles map[string]float64 `json:"quantiles,omitempty"`
+}
+
+// NOTE: MeanTime and SqDiffSum are the running mean and sum of squared differences from the mean used by
+// Welford's online algorithm, which avoids the catastrophic cancellation of a naive sum of squares.
+// NOTE: Series holds the data of each label set of an action keyed by labelKey, and is only used when labels are
+// enabled. The data of the action itself is the roll up of every series, Labels is only set on a series.
 type actionData struct {
 	TotalTime float64
 	CallCount float64
+	MinTime   float64
+	MaxTime   float64
+	MeanTime  float64
+	SqDiffSum float64
+	Sketch    *quantileSketch
+	Labels    map[string]string
+	Series    map[string]*actionData
 }
 
+// NOTE: actions are spread over shards by a hash of their name and each shard has its own lock, so adds to
+// different actions rarely contend with each other. An action is always in exactly one shard, so the stats of an
+// action are always consistent even though shards are locked one at a time.
 type safeActionDatastore struct {
-	Mux  sync.Mutex
-	Data map[string]*actionData
+	Shards []*actionShard
+}
+
+// NOTE: Version is incremented with atomics on every change so subscribers can check for changes without locking,
+// it is first in the struct so that it is 64 bit aligned on 32 bit platforms
+type actionShard struct {
+	Version uint64
+	Mux     sync.Mutex
+	Data    map[string]*actionData
+	_       [cacheLineSize]byte
 }
 
 // ActionAverage implements the ActionAverager interface
 type ActionAverage struct {
-	actionData *safeActionDatastore
+	actionData  *safeActionDatastore
+	policy      *validationPolicy
+	rejections  []uint64
+	deadLetters DeadLetterSink
+	clock       Clock
 }
 
 // NewActionAverager creates a new ActionAverager
 func NewActionAverager() ActionAverager {
+	return newActionAverage(newAveragerOptions())
+}
+
+func newActionAverage(options *averagerOptions) *ActionAverage {
+	shards := make([]*actionShard, numShards)
+	for i := range shards {
+		shards[i] = &actionShard{
+			Data: make(map[string]*actionData),
+		}
+	}
 	return &ActionAverage{
 		actionData: &safeActionDatastore{
-			Data: make(map[string]*actionData),
+			Shards: shards,
 		},
+		policy:      &options.policy,
+		rejections:  make([]uint64, len(rejectionReasons)+1),
+		deadLetters: options.deadLetters,
+		clock:       options.clock,
 	}
 }
 
 // AddAction takes a json serialized string and adds the action and time to the datastore
 func (acav *ActionAverage) AddAction(input string) error {
+	actStr, timeFlt, labels, err := acav.policy.parseLabeledAction(input)
+	if err != nil {
+		return acav.reject(input, err)
+	}
+
+	if !acav.addSample(actStr, timeFlt, 1, labels) {
+		return acav.reject(input, newLabelSetLimitError(actStr, input, acav.policy.maxLabelSets))
+	}
+	return nil
+}
+
+// ParseAction validates a json serialized action exactly like AddAction without adding it and returns its action
+// and time, so the action can be added with AddSample once something else that can fail, like logging it, is done.
+// Rejected actions are counted and recorded like AddAction. Labels are not returned.
+func (acav *ActionAverage) ParseAction(input string) (string, float64, error) {
+	actStr, timeFlt, _, err := acav.policy.parseLabeledAction(input)
+	if err != nil {
+		return "", 0, acav.reject(input, err)
+	}
+	return actStr, timeFlt, nil
+}
+
+// ValidateSample validates an action and time exactly like AddSample without adding them, rejected actions are
+// counted and recorded like AddSample
+func (acav *ActionAverage) ValidateSample(actStr string, timeFlt float64) error {
+	if err := acav.policy.validateSample(actStr, timeFlt); err != nil {
+		return acav.reject("", err)
+	}
+	return nil
+}
+
+// AddSample adds the action and time to the datastore, it accepts the same actions and times as AddAction
+func (acav *ActionAverage) AddSample(actStr string, timeFlt float64) error {
+	return acav.AddLabeledSample(actStr, timeFlt, nil)
+}
+
+// AddWeightedSample adds the action and time to the datastore as if the time had been added weight times, e.g. a
+// time sampled at a rate of 0.1 is added with a weight of 10. The count, sum and quantiles are scaled by the weight
+// and the average and variance are of the weighted times. It accepts the same actions and times as AddSample, and
+// weight must be a finite number greater than 0.
+func (acav *ActionAverage) AddWeightedSample(actStr string, timeFlt float64, weight float64) error {
+	if err := acav.policy.validateSample(actStr, timeFlt); err != nil {
+		return acav.reject("", err)
+	}
+	// NOTE: the weight is not part of the input, so a bad weight is an error of the caller and not a rejection.
+	// The negated comparison rejects NaN as well.
+	if !(weight > 0) || math.IsInf(weight, 1) {
+		return fmt.Errorf("weight %v for action %s is not a finite number greater than 0", weight, actStr)
+	}
+
+	if !acav.addSample(actStr, timeFlt, weight, nil) {
+		return acav.reject("", newLabelSetLimitError(actStr, "", acav.policy.maxLabelSets))
+	}
+	return nil
+}
+
+// AddDuration adds the action and duration to the datastore as a time in milliseconds
+func (acav *ActionAverage) AddDuration(actStr string, duration time.Duration) error {
+	return acav.AddSample(actStr, durationToTime(duration))
+}
+
+// addSample adds a validated action, time, weight and labels, it returns false if the action already has the max
+// number of label sets and the labels are a new label set
+func (acav *ActionAverage) addSample(actStr string, timeFlt float64, weight float64, labels map[string]string) bool {
+	if acav.policy.ignore(timeFlt) {
+		return true
+	}
+
+	shard := acav.actionData.shard(actStr)
+	shard.Mux.Lock()
+	defer shard.Mux.Unlock()
+
+	return shard.add(actStr, timeFlt, weight, labels, acav.policy.maxLabelSets)
+}
+
+// shard returns the shard that holds actStr
+func (sad *safeActionDatastore) shard(actStr string) *actionShard {
+	return sad.Shards[shardIndex(actStr)]
+}
+
+// shardIndex picks the shard for actStr with an inlined 32 bit FNV-1a hash, so that hashing does not allocate
+func shardIndex(actStr string) int {
+	hash := uint32(fnvOffset)
+	for i := 0; i < len(actStr); i++ {
+		hash ^= uint32(actStr[i])
+		hash *= fnvPrime
+	}
+	return int(hash & (numShards - 1))
+}
+
+// lockAll locks every shard in order, for changes that must be seen all at once like Restore. Shards are always
+// locked in the same order so lockAll can not deadlock with itself.
+func (sad *safeActionDatastore) lockAll() {
+	for _, shard := range sad.Shards {
+		shard.Mux.Lock()
+	}
+}
+
+func (sad *safeActionDatastore) unlockAll() {
+	for _, shard := range sad.Shards {
+		shard.Mux.Unlock()
+	}
+}
+
+// version returns the sum of the versions of every shard, every shard version only ever increases so the sum
+// changes whenever any shard changes
+func (sad *safeActionDatastore) version() uint64 {
+	var version uint64
+	for _, shard := range sad.Shards {
+		version += atomic.LoadUint64(&shard.Version)
+	}
+	return version
+}
+
+// add adds a validated action, time and weight to the shard, along with its labels when maxLabelSets is greater
+// than 0. It returns false without adding anything if the labels are a new label set and the action already has
+// maxLabelSets label sets. The shard must be locked by the caller.
+func (shard *actionShard) add(actStr string, timeFlt float64, weight float64, labels map[string]string, maxLabelSets int) bool {
+	// Check if action is already tracked in datastore if not add an entry for it, otherwise update existing entry
+	data, ok := shard.Data[actStr]
+
+	// NOTE: the label set limit is checked before anything is added, so a rejected add does not change any stats
+	var key string
+	var series *actionData
+	if maxLabelSets > 0 {
+		key = labelKey(labels)
+		if ok {
+			series = data.Series[key]
+			if series == nil && len(data.Series) >= maxLabelSets {
+				return false
+			}
+		}
+	}
+
+	atomic.AddUint64(&shard.Version, 1)
+	if ok {
+		// NOTE: data is a pointer to an actionData object so this will update the underlying object
+		data.add(timeFlt, weight)
+	} else {
+		data = newActionData(timeFlt, weight)
+		shard.Data[actStr] = data
+	}
+
+	if maxLabelSets > 0 {
+		if series != nil {
+			series.add(timeFlt, weight)
+		} else {
+			series = newActionData(timeFlt, weight)
+			series.Labels = labels
+			if data.Series == nil {
+				data.Series = make(map[string]*actionData)
+			}
+			data.Series[key] = series
+		}
+	}
+	return true
+}
+
+func newActionData(timeFlt float64, weight float64) *actionData {
+	ad := &actionData{
+		TotalTime: timeFlt * weight,
+		CallCount: weight,
+		MinTime:   timeFlt,
+		MaxTime:   timeFlt,
+		MeanTime:  timeFlt,
+		Sketch:    newQuantileSketch(),
+	}
+	ad.Sketch.add(timeFlt, weight)
+	return ad
+}
+
+// add adds a time to the running stats as if it was added weight times, this is West's weighted form of Welford's
+// algorithm, which is exactly Welford's algorithm for a weight of 1
+func (ad *actionData) add(timeFlt float64, weight float64) {
+	ad.TotalTime += timeFlt * weight
+	ad.CallCount += weight
+	if timeFlt < ad.MinTime {
+		ad.MinTime = timeFlt
+	}
+	if timeFlt > ad.MaxTime {
+		ad.MaxTime = timeFlt
+	}
+	delta := timeFlt - ad.MeanTime
+	ad.MeanTime += delta * weight / ad.CallCount
+	ad.SqDiffSum += weight * delta * (timeFlt - ad.MeanTime)
+	ad.Sketch.add(timeFlt, weight)
+}
+
+// GetStats computes the average, count, sum, min, max, variance and standard deviation of the times for each
+// action in the datastore ordered by action name
+func (acav *ActionAverage) GetStats() string {
+	return marshalStats(acav.computeStats(nil))
+}
+
+// GetStatsWithQuantiles computes the same stats as GetStats along with estimates of each of the quantiles,
+// which must be between 0 and 1, keyed by the quantile provided e.g. "0.99". Estimates are within 1% of the
+// exact value of the quantile, where the exact value is the value at the floor of quantile*(count-1) in the
+// sorted times of an action, and are never outside of the min and max of an action.
+func (acav *ActionAverage) GetStatsWithQuantiles(quantiles ...float64) (string, error) {
+	for _, quantile := range quantiles {
+		// NOTE: negated comparison so that NaN is rejected as well
+		if !(quantile >= 0 && quantile <= 1) {
+			return "", fmt.Errorf("quantile %v is not between 0 and 1, rejecting", quantile)
+		}
+	}
+
+	return marshalStats(acav.computeStats(quantiles)), nil
+}
+
+// GetStatsList computes the same stats in the same order as GetStats, but returns them as a slice instead of json
+func (acav *ActionAverage) GetStatsList() []ActionStats {
+	return acav.computeStats(nil)
+}
+
+// GetActionStats computes the same stats as GetStats for a single action, it returns false if the action has
+// not been added
+func (acav *ActionAverage) GetActionStats(actStr string) (ActionStats, bool) {
+	shard := acav.actionData.shard(actStr)
+	shard.Mux.Lock()
+	defer shard.Mux.Unlock()
+
+	data, ok := shard.Data[actStr]
+	if !ok || data.CallCount <= 0 {
+		return ActionStats{}, false
+	}
+	return computeActionStats(actStr, data, nil), true
+}
+
+// computeStats builds the stats for each action in the datastore ordered by action name, each shard is locked
+// while its stats are computed
+func (acav *ActionAverage) computeStats(quantiles []float64) []ActionStats {
+	var output []ActionStats
+	for _, shard := range acav.actionData.Shards {
+		output = shard.appendStats(output, quantiles)
+	}
+	sortStats(output, SortByAction, Ascending)
+	return output
+}
+
+// appendStats appends the stats of each action in the shard to output
+func (shard *actionShard) appendStats(output []ActionStats, quantiles []float64) []ActionStats {
+	// NOTE: the defer unlock could be moved to before computing for performance, but is here for organization
+	shard.Mux.Lock()
+	defer shard.Mux.Unlock()
+
+	for action, data := range shard.Data {
+		// NOTE: future proofing against possible divide by 0, currently this should not be possible
+		if data.CallCount <= 0 {
+			continue
+		}
+		output = append(output, computeActionStats(action, data, quantiles))
+	}
+	return output
+}
+
+// NOTE: everything in the returned ActionStats is either a value or newly allocated, so it does not share any
+// memory with the datastore and is safe to use after the datastore is unlocked
+func computeActionStats(actStr string, data *actionData, quantiles []float64) ActionStats {
+	// NOTE: divisions are expensive so only calculate averages when asked not as actions are added
+	// NOTE: variance is the population variance of all of the times added for the action
+	variance := data.SqDiffSum / data.CallCount
+	stats := ActionStats{
+		Action:   actStr,
+		Average:  data.TotalTime / data.CallCount,
+		Count:    data.CallCount,
+		Sum:      data.TotalTime,
+		Min:      data.MinTime,
+		Max:      data.MaxTime,
+		Variance: variance,
+		StdDev:   math.Sqrt(variance),
+	}
+	if len(quantiles) > 0 {
+		stats.Quantiles = make(map[string]float64, len(quantiles))
+		for i, estimate := range data.Sketch.quantiles(quantiles) {
+			// NOTE: the true quantile is always between the min and max so clamping only improves the estimate
+			estimate = math.Max(data.MinTime, math.Min(data.MaxTime, estimate))
+			stats.Quantiles[strconv.FormatFloat(quantiles[i], 'f', -1, 64)] = estimate
+		}
+	}
+	return stats
+}
+
+// parseAction validates a json serialized action with the default policy and returns its action and time, every
+// averager uses this so that they all accept and reject the same input
+func parseAction(input string) (string, float64, error) {
+	return defaultPolicy.parseAction(input)
+}
+
+// validateSample validates an action and time that did not come from parseAction with the default policy
+func validateSample(actStr string, timeFlt float64) error {
+	return defaultPolicy.validateSample(actStr, timeFlt)
+}
+
+// parseAction validates a json serialized action and returns its action and time, labels are only accepted if the
+// policy has labels enabled and are dropped
+func (vp *validationPolicy) parseAction(input string) (string, float64, error) {
+	actStr, timeFlt, _, err := vp.parseLabeledAction(input)
+	return actStr, timeFlt, err
+}
+
+// parseLabeledAction validates a json serialized action and returns its action, time and labels, labels are nil
+// unless the policy has labels enabled and the input has a "labels" field
+func (vp *validationPolicy) parseLabeledAction(input string) (string, float64, map[string]string, error) {
 	// NOTE: not doing an unmarshal to an explicit struct here, since input like
 	// {"action":"run","random":"random"} will give {action:"run",time:0} and
 	// {"action":"run","time":20,"random":"randon"} will give {action:"run",time:20}
 	// unmarshaling to an interface allows explicit verification of fields
 	var inInterface interface{}
 	if err := json.Unmarshal([]byte(input), &inInterface); err != nil {
-		return err
+		// NOTE: the json error is kept as the message and as the underlying error, so callers that match on it
+		// still can
+		validationErr := newValidationError("", ErrInvalidJSON, input, "%s", err.Error())
+		validationErr.Err = err
+		return "", 0, nil, validationErr
 	}
 
 	inMap, ok := inInterface.(map[string]interface{})
 	if !ok {
-		return fmt.Errorf("unable to convert input %s to internal data, rejecting", input)
+		return "", 0, nil, newValidationError("", ErrNotObject, input, "unable to convert input %s to internal data, rejecting", input)
 	}
 
+	// NOTE: a lenient policy ignores extra fields, but still requires the action and time fields below
 	numKeys := len(inMap)
-	if numKeys != expInputLen {
-		return fmt.Errorf("unexpected number of fields, %d, in input %s, expect %d, rejecting", numKeys, input, expInputLen)
+	expLen := expInputLen
+	rawLabels, hasLabels := inMap[labelsKey]
+	hasLabels = hasLabels && vp.maxLabelSets > 0
+	if hasLabels {
+		expLen++
+	}
+	if numKeys != expLen && !vp.lenientFields {
+		return "", 0, nil, newValidationError("", ErrFieldCount, input, "unexpected number of fields, %d, in input %s, expect %d, rejecting", numKeys, input, expLen)
 	}
 
 	action, ok := inMap[actKey]
 	if !ok {
-		return fmt.Errorf(`input %s is missing "action" field, rejecting`, input)
+		return "", 0, nil, newValidationError(actKey, ErrMissingField, input, `input %s is missing "action" field, rejecting`, input)
 	}
 	actStr, ok := action.(string)
 	if !ok {
-		return fmt.Errorf("action field is not a string in input %s, rejecting", input)
+		return "", 0, nil, newValidationError(actKey, ErrWrongType, input, "action field is not a string in input %s, rejecting", input)
 	}
 
 	time, ok := inMap[timeKey]
 	if !ok {
-		return fmt.Errorf(`input %s is missing "time" field, rejecting`, input)
+		return "", 0, nil, newValidationError(timeKey, ErrMissingField, input, `input %s is missing "time" field, rejecting`, input)
 	}
 	timeFlt, ok := time.(float64)
 	if !ok {
-		return fmt.Errorf("time field is not a number in input %s, rejecting", input)
+		return "", 0, nil, newValidationError(timeKey, ErrWrongType, input, "time field is not a number in input %s, rejecting", input)
 	}
 	if timeFlt < 0 {
-		return fmt.Errorf("negative time value for input %s, rejecting", input)
+		return "", 0, nil, newValidationError(timeKey, ErrNegativeTime, input, "negative time value for input %s, rejecting", input)
+	}
+	if err := vp.check(actStr, timeFlt, input); err != nil {
+		return "", 0, nil, err
 	}
 
-	acav.actionData.Mux.Lock()
-	defer acav.actionData.Mux.Unlock()
-
-	// Check if action is already tracked in datastore if not add an entry for it, otherwise update existing entry
-	data, ok := acav.actionData.Data[actStr]
-	if ok {
-		// NOTE: data is a pointer to an actionData object so this will update the underlying object
-		data.TotalTime += timeFlt
-		data.CallCount++
-	} else {
-		ad := &actionData{
-			TotalTime: timeFlt,
-			CallCount: 1,
+	var labels map[string]string
+	if hasLabels {
+		var err error
+		if labels, err = parseLabels(rawLabels, input); err != nil {
+			return "", 0, nil, err
 		}
-		acav.actionData.Data[actStr] = ad
 	}
+	return actStr, timeFlt, labels, nil
+}
 
-	return nil
+// validateSample validates an action and time that did not come from parseAction. NaN and infinite times can not
+// be represented in json so they are rejected here as well.
+func (vp *validationPolicy) validateSample(actStr string, timeFlt float64) error {
+	if math.IsNaN(timeFlt) || math.IsInf(timeFlt, 0) {
+		return newValidationError(timeKey, ErrNonFiniteTime, "", "time value %v for action %s is not a finite number, rejecting", timeFlt, actStr)
+	}
+	if timeFlt < 0 {
+		return newValidationError(timeKey, ErrNegativeTime, "", "negative time value %v for action %s, rejecting", timeFlt, actStr)
+	}
+	return vp.check(actStr, timeFlt, "")
 }
 
-// GetStats computes the average time for each action in the datastore
-func (acav *ActionAverage) GetStats() string {
-	// NOTE: the defer unlock could be moved to after the for loop for performance, but is here for organization
-	acav.actionData.Mux.Lock()
-	defer acav.actionData.Mux.Unlock()
+// durationToTime converts a duration to a time in milliseconds, the unit used in examples of input
+func durationToTime(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
 
-	var output []*outputJSON
-	for action, data := range acav.actionData.Data {
-		// NOTE: future proofing against possible divide by 0, currently this should not be possible
-		if data.CallCount <= 0 {
-			continue
-		}
-		// NOTE: divisions are expensive so only calculate averages when asked not as actions are added
-		item := &outputJSON{
-			Action:  action,
-			Average: data.TotalTime / data.CallCount,
-		}
-		output = append(output, item)
+func marshalStats(output []ActionStats) string {
+	// Return an empty json array if output is empty
+	if len(output) == 0 {
+		return emptyArrayJSON
 	}
 
+	return marshalJSON(output)
+}
+
+// actionOutputJSON is the json output of a single action of an averager that does not output ActionStats
+type actionOutputJSON interface {
+	actionName() string
+}
+
+// marshalSortedByAction marshals output ordered by action name, since map order is random sorting gives the same
+// output for the same stats
+func marshalSortedByAction(output []actionOutputJSON) string {
 	// Return an empty json array if output is empty
 	if len(output) == 0 {
 		return emptyArrayJSON
 	}
 
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].actionName() < output[j].actionName()
+	})
+	return marshalJSON(output)
+}
+
+func marshalJSON(output interface{}) string {
 	// WARNING: should not suppress error, but have to because of assignment constraints.
 	// However, this should not be an issue, since proper formatting is handled on our end.
 	jsonBytes, _ := json.Marshal(output)
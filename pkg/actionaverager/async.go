@@ -0,0 +1,173 @@
+package actionaverager
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// QueuePolicy is what an AsyncActionAverage does with an action when its queue is full
+type QueuePolicy int
+
+const (
+	// QueueBlock waits until there is room in the queue
+	QueueBlock QueuePolicy = iota
+	// QueueDropNewest drops the action being added and returns ErrQueueFull
+	QueueDropNewest
+	// QueueDropOldest drops the oldest action in the queue to make room for the action being added
+	QueueDropOldest
+)
+
+// AsyncActionAverage implements the ActionAverager interface by queueing actions for worker goroutines to add to
+// another ActionAverager, so AddAction never waits on the lock of the averager. Since actions are added later,
+// AddAction can not return the error of an invalid action, those are passed to the error handler instead. With
+// more than one worker, actions are not necessarily added in the order they were queued.
+type AsyncActionAverage struct {
+	// NOTE: dropped is first in the struct so that it is 64 bit aligned on 32 bit platforms
+	dropped    uint64
+	averager   ActionAverager
+	policy     QueuePolicy
+	errHandler func(error)
+	queue      chan string
+	// NOTE: closeMux is read locked while sending to the queue and locked to close it, so the queue is never sent
+	// to after it is closed
+	closeMux   sync.RWMutex
+	closed     bool
+	pendingMux sync.Mutex
+	pendingCnd *sync.Cond
+	pending    int
+	workers    sync.WaitGroup
+}
+
+// NewAsyncActionAverager creates a new AsyncActionAverager that queues up to queueSize actions for numWorkers
+// workers to add to averager, errHandler is called with the error of every action averager rejects and may be nil.
+// Close must be called to stop the workers.
+func NewAsyncActionAverager(averager ActionAverager, queueSize int, numWorkers int, policy QueuePolicy,
+	errHandler func(error)) (*AsyncActionAverage, error) {
+	if queueSize <= 0 {
+		return nil, fmt.Errorf("queue size, %d, must be greater than 0", queueSize)
+	}
+	if numWorkers <= 0 {
+		return nil, fmt.Errorf("number of workers, %d, must be greater than 0", numWorkers)
+	}
+	if policy != QueueBlock && policy != QueueDropNewest && policy != QueueDropOldest {
+		return nil, fmt.Errorf("unknown queue policy %d", policy)
+	}
+	if errHandler == nil {
+		errHandler = func(error) {}
+	}
+
+	asav := &AsyncActionAverage{
+		averager:   averager,
+		policy:     policy,
+		errHandler: errHandler,
+		queue:      make(chan string, queueSize),
+	}
+	asav.pendingCnd = sync.NewCond(&asav.pendingMux)
+	asav.workers.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go asav.work()
+	}
+	return asav, nil
+}
+
+// AddAction queues the json serialized action to be added by a worker, it only returns an error if the action is
+// dropped or the averager is closed
+func (asav *AsyncActionAverage) AddAction(input string) error {
+	asav.closeMux.RLock()
+	defer asav.closeMux.RUnlock()
+
+	if asav.closed {
+		return ErrClosed
+	}
+
+	asav.addPending(1)
+	switch asav.policy {
+	case QueueDropNewest:
+		select {
+		case asav.queue <- input:
+		default:
+			asav.drop()
+			return ErrQueueFull
+		}
+	case QueueDropOldest:
+		for {
+			select {
+			case asav.queue <- input:
+				return nil
+			default:
+			}
+			// NOTE: a worker or another sender can empty or fill the queue at any time, so only drop the oldest
+			// action if there still is one and try again
+			select {
+			case <-asav.queue:
+				asav.drop()
+			default:
+			}
+		}
+	default:
+		asav.queue <- input
+	}
+	return nil
+}
+
+// GetStats returns the stats of the averager actions are added to, actions that are still queued are not included
+// until they are added, call Flush first to include them
+func (asav *AsyncActionAverage) GetStats() string {
+	return asav.averager.GetStats()
+}
+
+// Dropped returns the number of actions that have been dropped because the queue was full
+func (asav *AsyncActionAverage) Dropped() uint64 {
+	return atomic.LoadUint64(&asav.dropped)
+}
+
+// Flush waits until every queued action has been added, actions queued while waiting are waited for as well
+func (asav *AsyncActionAverage) Flush() {
+	asav.pendingMux.Lock()
+	defer asav.pendingMux.Unlock()
+
+	for asav.pending > 0 {
+		asav.pendingCnd.Wait()
+	}
+}
+
+// Close stops queueing actions, waits until every queued action has been added and stops the workers. It is safe to
+// call more than once.
+func (asav *AsyncActionAverage) Close() error {
+	asav.closeMux.Lock()
+	if !asav.closed {
+		asav.closed = true
+		close(asav.queue)
+	}
+	asav.closeMux.Unlock()
+
+	asav.workers.Wait()
+	return nil
+}
+
+func (asav *AsyncActionAverage) work() {
+	defer asav.workers.Done()
+
+	for input := range asav.queue {
+		if err := asav.averager.AddAction(input); err != nil {
+			asav.errHandler(err)
+		}
+		asav.addPending(-1)
+	}
+}
+
+func (asav *AsyncActionAverage) drop() {
+	atomic.AddUint64(&asav.dropped, 1)
+	asav.addPending(-1)
+}
+
+func (asav *AsyncActionAverage) addPending(delta int) {
+	asav.pendingMux.Lock()
+	defer asav.pendingMux.Unlock()
+
+	asav.pending += delta
+	if asav.pending == 0 {
+		asav.pendingCnd.Broadcast()
+	}
+}
@@ -0,0 +1,121 @@
+package actionaverager
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// NOTE: times are stored as float64 bits in uint64s so they can be updated with atomics, every field is 64 bits
+// so every field is 64 bit aligned on 32 bit platforms as long as the struct is allocated on its own
+type atomicActionData struct {
+	TotalBits uint64
+	CallCount uint64
+	MinBits   uint64
+	MaxBits   uint64
+}
+
+// AtomicActionAverage implements the ActionAverager interface without any locks. Each action has a cell that is
+// updated with atomic operations, so adds never wait on each other except to retry a compare and swap, and only
+// the first add of an action writes to the map of cells. Since each stat is updated separately, GetStats can see
+// an add that is still in flight in some stats but not yet in others, once every add has returned the stats are
+// exact. It tracks the average, count, sum, min and max of each action, but not variance or quantiles, since
+// those can not be updated with a single atomic operation.
+type AtomicActionAverage struct {
+	actionData sync.Map
+}
+
+// NewAtomicActionAverager creates a new ActionAverager that updates existing actions with atomic operations
+func NewAtomicActionAverager() ActionAverager {
+	return &AtomicActionAverage{}
+}
+
+// AddAction takes a json serialized string and adds the action and time to the cell of the action
+func (aav *AtomicActionAverage) AddAction(input string) error {
+	actStr, timeFlt, err := parseAction(input)
+	if err != nil {
+		return err
+	}
+
+	aav.addSample(actStr, timeFlt)
+	return nil
+}
+
+// AddSample adds the action and time, it accepts the same actions and times as AddAction
+func (aav *AtomicActionAverage) AddSample(actStr string, timeFlt float64) error {
+	if err := validateSample(actStr, timeFlt); err != nil {
+		return err
+	}
+
+	aav.addSample(actStr, timeFlt)
+	return nil
+}
+
+func (aav *AtomicActionAverage) addSample(actStr string, timeFlt float64) {
+	// NOTE: Load before LoadOrStore so that adding to an existing action does not allocate a new cell
+	value, ok := aav.actionData.Load(actStr)
+	if !ok {
+		value, _ = aav.actionData.LoadOrStore(actStr, &atomicActionData{
+			MinBits: math.Float64bits(math.Inf(1)),
+		})
+	}
+	data := value.(*atomicActionData)
+
+	atomicAddFloat(&data.TotalBits, timeFlt)
+	atomicMinFloat(&data.MinBits, timeFlt)
+	atomicMaxFloat(&data.MaxBits, timeFlt)
+	// NOTE: the count is updated last and loaded first, so a count of 0 means no other stat has been updated yet
+	atomic.AddUint64(&data.CallCount, 1)
+}
+
+// GetStats computes the average, count, sum, min and max time for each action ordered by action name
+func (aav *AtomicActionAverage) GetStats() string {
+	var output []actionOutputJSON
+	aav.actionData.Range(func(key, value interface{}) bool {
+		data := value.(*atomicActionData)
+		count := float64(atomic.LoadUint64(&data.CallCount))
+		// NOTE: the cell was just created and its first add has not finished yet
+		if count == 0 {
+			return true
+		}
+		sum := math.Float64frombits(atomic.LoadUint64(&data.TotalBits))
+		output = append(output, &basicOutputJSON{
+			Action:  key.(string),
+			Average: sum / count,
+			Count:   count,
+			Sum:     sum,
+			Min:     math.Float64frombits(atomic.LoadUint64(&data.MinBits)),
+			Max:     math.Float64frombits(atomic.LoadUint64(&data.MaxBits)),
+		})
+		return true
+	})
+
+	return marshalSortedByAction(output)
+}
+
+func atomicAddFloat(bits *uint64, value float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		if atomic.CompareAndSwapUint64(bits, old, math.Float64bits(math.Float64frombits(old)+value)) {
+			return
+		}
+	}
+}
+
+func atomicMinFloat(bits *uint64, value float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		if value >= math.Float64frombits(old) || atomic.CompareAndSwapUint64(bits, old, math.Float64bits(value)) {
+			return
+		}
+	}
+}
+
+func atomicMaxFloat(bits *uint64, value float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		if value <= math.Float64frombits(old) || atomic.CompareAndSwapUint64(bits, old, math.Float64bits(value)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package actionaverager
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BatchItemError is the error for a single rejected action in a batch
+type BatchItemError struct {
+	Index int
+	Err   error
+}
+
+func (bie *BatchItemError) Error() string {
+	return fmt.Sprintf("index %d: %v", bie.Index, bie.Err)
+}
+
+// Unwrap returns the error the action was rejected with
+func (bie *BatchItemError) Unwrap() error {
+	return bie.Err
+}
+
+// BatchError reports every rejected action in a batch in order of index, the actions that were not rejected
+// have still been added
+type BatchError struct {
+	Errors []*BatchItemError
+}
+
+func (be *BatchError) Error() string {
+	itemErrs := make([]string, len(be.Errors))
+	for i := range be.Errors {
+		itemErrs[i] = be.Errors[i].Error()
+	}
+	return fmt.Sprintf("rejected %d actions in batch, %s", len(be.Errors), strings.Join(itemErrs, "; "))
+}
+
+// batchAction is a validated action of a batch along with its index in the batch
+type batchAction struct {
+	index   int
+	actStr  string
+	timeFlt float64
+	labels  map[string]string
+}
+
+// AddActions takes a json serialized array of actions like [{"action":"run","time":50}, ...] and adds every
+// action that AddAction would accept to the datastore, locking each shard at most once. If the input is not a json
+// array nothing is added and the json error is returned, otherwise if any actions are rejected a *BatchError is
+// returned.
+func (acav *ActionAverage) AddActions(input string) error {
+	var rawActions []json.RawMessage
+	if err := json.Unmarshal([]byte(input), &rawActions); err != nil {
+		return acav.reject(input, err)
+	}
+
+	actions := make([]batchAction, 0, len(rawActions))
+	shardActions := make([][]int, numShards)
+	batchErr := &BatchError{}
+	for i := range rawActions {
+		actStr, timeFlt, labels, err := acav.policy.parseLabeledAction(string(rawActions[i]))
+		if err != nil {
+			err = acav.reject(string(rawActions[i]), err)
+			batchErr.Errors = append(batchErr.Errors, &BatchItemError{Index: i, Err: err})
+			continue
+		}
+		if acav.policy.ignore(timeFlt) {
+			continue
+		}
+		index := shardIndex(actStr)
+		shardActions[index] = append(shardActions[index], len(actions))
+		actions = append(actions, batchAction{index: i, actStr: actStr, timeFlt: timeFlt, labels: labels})
+	}
+
+	// NOTE: every action is validated and grouped by shard before locking, so each shard is only locked once and
+	// only while it is updated. Actions in the same shard are added in the order of the batch.
+	numParseErrs := len(batchErr.Errors)
+	for index, indexes := range shardActions {
+		if len(indexes) == 0 {
+			continue
+		}
+		rejected := acav.actionData.Shards[index].addBatch(actions, indexes, acav.policy.maxLabelSets)
+		for _, i := range rejected {
+			action := &actions[i]
+			rawAction := string(rawActions[action.index])
+			err := acav.reject(rawAction, newLabelSetLimitError(action.actStr, rawAction, acav.policy.maxLabelSets))
+			batchErr.Errors = append(batchErr.Errors, &BatchItemError{Index: action.index, Err: err})
+		}
+	}
+
+	// NOTE: an empty *BatchError is still a non nil error so return nil when nothing was rejected
+	if len(batchErr.Errors) == 0 {
+		return nil
+	}
+	if len(batchErr.Errors) > numParseErrs {
+		sort.Slice(batchErr.Errors, func(i, j int) bool {
+			return batchErr.Errors[i].Index < batchErr.Errors[j].Index
+		})
+	}
+	return batchErr
+}
+
+// addBatch adds the actions at each of indexes to the shard under a single lock, it returns the indexes of the
+// actions that were rejected for having a new label set when their action already has the max number of label sets
+func (shard *actionShard) addBatch(actions []batchAction, indexes []int, maxLabelSets int) []int {
+	shard.Mux.Lock()
+	defer shard.Mux.Unlock()
+
+	var rejected []int
+	for _, i := range indexes {
+		action := &actions[i]
+		if !shard.add(action.actStr, action.timeFlt, 1, action.labels, maxLabelSets) {
+			rejected = append(rejected, i)
+		}
+	}
+	return rejected
+}
@@ -0,0 +1,114 @@
+package actionaverager
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DeadLetter is a single rejected action
+type DeadLetter struct {
+	// Time is when the action was rejected
+	Time time.Time `json:"time"`
+	// Input is the raw rejected payload, it is empty for AddSample since there is no payload, the error has the
+	// action and time instead
+	Input string `json:"input"`
+	// Reason is the name of the reason the action was rejected, the same as in RejectionStats
+	Reason string `json:"reason"`
+	// Error is the message of the error the action was rejected with
+	Error string `json:"error"`
+}
+
+// DeadLetterSink records rejected actions for later inspection. Record is called while the action is being
+// added, so it must be safe to call concurrently and should not block.
+type DeadLetterSink interface {
+	Record(*DeadLetter)
+}
+
+// DeadLetterFunc is a DeadLetterSink that calls a function with every dead letter
+type DeadLetterFunc func(*DeadLetter)
+
+// Record calls the function with deadLetter
+func (dlf DeadLetterFunc) Record(deadLetter *DeadLetter) {
+	dlf(deadLetter)
+}
+
+// ChannelDeadLetterSink is a DeadLetterSink that sends every dead letter on a channel. It never blocks, if the
+// channel is full the dead letter is dropped and counted instead.
+type ChannelDeadLetterSink struct {
+	// NOTE: dropped is first in the struct so that it is 64 bit aligned on 32 bit platforms
+	dropped uint64
+	deadCh  chan<- *DeadLetter
+}
+
+// NewChannelDeadLetterSink creates a new ChannelDeadLetterSink that sends on deadCh, which should be buffered
+func NewChannelDeadLetterSink(deadCh chan<- *DeadLetter) *ChannelDeadLetterSink {
+	return &ChannelDeadLetterSink{
+		deadCh: deadCh,
+	}
+}
+
+// Record sends deadLetter on the channel if there is room
+func (cdls *ChannelDeadLetterSink) Record(deadLetter *DeadLetter) {
+	select {
+	case cdls.deadCh <- deadLetter:
+	default:
+		atomic.AddUint64(&cdls.dropped, 1)
+	}
+}
+
+// Dropped returns the number of dead letters dropped because the channel was full
+func (cdls *ChannelDeadLetterSink) Dropped() uint64 {
+	return atomic.LoadUint64(&cdls.dropped)
+}
+
+// FileDeadLetterSink is a DeadLetterSink that appends every dead letter to a file as a line of json, so the file
+// can be read back with any newline delimited json tool
+type FileDeadLetterSink struct {
+	mux     sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	err     error
+}
+
+// NewFileDeadLetterSink opens path for appending, creating it if needed, Close must be called to close the file
+func NewFileDeadLetterSink(path string) (*FileDeadLetterSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDeadLetterSink{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+// Record appends deadLetter to the file, once a write fails nothing else is written and Close returns the error
+func (fdls *FileDeadLetterSink) Record(deadLetter *DeadLetter) {
+	fdls.mux.Lock()
+	defer fdls.mux.Unlock()
+
+	if fdls.err != nil {
+		return
+	}
+	// NOTE: Encode writes the whole line with a single write, so a line is never interleaved with another
+	fdls.err = fdls.encoder.Encode(deadLetter)
+}
+
+// Close closes the file, it returns the first error writing to the file if there was one. Dead letters recorded
+// after it is closed are not written.
+func (fdls *FileDeadLetterSink) Close() error {
+	fdls.mux.Lock()
+	defer fdls.mux.Unlock()
+
+	closeErr := fdls.file.Close()
+	writeErr := fdls.err
+	if writeErr == nil {
+		// NOTE: stops Record from writing to the closed file
+		fdls.err = os.ErrClosed
+		return closeErr
+	}
+	return writeErr
+}
@@ -0,0 +1,77 @@
+package actionaverager
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidJSON is the reason for input that is not valid json
+	ErrInvalidJSON = errors.New("invalid json")
+	// ErrNotObject is the reason for input that is valid json but not a json object
+	ErrNotObject = errors.New("input is not a json object")
+	// ErrFieldCount is the reason for input that does not have exactly the "action" and "time" fields
+	ErrFieldCount = errors.New("unexpected number of fields")
+	// ErrMissingField is the reason for input that is missing the "action" or "time" field
+	ErrMissingField = errors.New("missing field")
+	// ErrWrongType is the reason for an "action" that is not a string or a "time" that is not a number
+	ErrWrongType = errors.New("wrong field type")
+	// ErrNegativeTime is the reason for a time that is less than 0
+	ErrNegativeTime = errors.New("negative time")
+	// ErrNonFiniteTime is the reason for a time that is NaN or infinite, which can only be added with AddSample
+	ErrNonFiniteTime = errors.New("time is not a finite number")
+	// ErrTimeOutOfRange is the reason for a time outside of the range set with WithTimeRange
+	ErrTimeOutOfRange = errors.New("time out of range")
+	// ErrZeroTime is the reason for a time of 0 with the ZeroTimeReject policy
+	ErrZeroTime = errors.New("zero time")
+	// ErrActionTooLong is the reason for an action longer than the length set with WithMaxActionLength
+	ErrActionTooLong = errors.New("action too long")
+	// ErrActionNotAllowed is the reason for an action not allowed by WithAllowedActions, WithActionPattern or
+	// WithHierarchy
+	ErrActionNotAllowed = errors.New("action not allowed")
+	// ErrCardinalityLimit is the reason for an action with too many labels, or with a new label set when the
+	// action already has the max number of label sets set with WithLabels
+	ErrCardinalityLimit = errors.New("cardinality limit")
+
+	// ErrQueueFull is returned by an AsyncActionAverage with QueueDropNewest when an action is dropped
+	ErrQueueFull = errors.New("queue is full, dropping action")
+	// ErrClosed is returned by an AsyncActionAverage or a write-ahead log when an action is added after it is closed
+	ErrClosed = errors.New("averager is closed, rejecting")
+)
+
+// ValidationError is the error for a rejected action. errors.Is matches it against its Reason, which is one of
+// the Err sentinel errors above, and errors.As can get it from any error that wraps it, like a BatchItemError.
+type ValidationError struct {
+	// Field is the field that was rejected, "action", "time" or "labels", or empty if the input as a whole was rejected
+	Field string
+	// Reason is the sentinel error for why the action was rejected
+	Reason error
+	// Input is the rejected json input, or empty if the action was added with AddSample
+	Input string
+	// Err is the underlying error, like the json syntax error of ErrInvalidJSON, or nil if there is not one
+	Err error
+	msg string
+}
+
+func newValidationError(field string, reason error, input string, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{
+		Field:  field,
+		Reason: reason,
+		Input:  input,
+		msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (ve *ValidationError) Error() string {
+	return ve.msg
+}
+
+// Is reports whether target is the Reason of the error
+func (ve *ValidationError) Is(target error) bool {
+	return target == ve.Reason
+}
+
+// Unwrap returns the underlying error, if there is one
+func (ve *ValidationError) Unwrap() error {
+	return ve.Err
+}
@@ -0,0 +1,135 @@
+package actionaverager
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+type ewmaOutputJSON struct {
+	Action  string  `json:"action"`
+	Average float64 `json:"avg"`
+	Count   float64 `json:"count"`
+}
+
+func (eo *ewmaOutputJSON) actionName() string {
+	return eo.Action
+}
+
+// NOTE: WeightedTime and Weight are the sum of the times and the sum of the weights of the times, both decayed
+// to LastUpdate, the weight of a time halves every half-life so their ratio is the exponentially weighted average
+type ewmaActionData struct {
+	WeightedTime float64
+	Weight       float64
+	CallCount    float64
+	LastUpdate   time.Time
+}
+
+type safeEWMADatastore struct {
+	Mux  sync.Mutex
+	Data map[string]*ewmaActionData
+}
+
+// EWMAActionAverage implements the ActionAverager interface with an exponentially weighted moving average, where
+// the weight of each time added for an action halves every half-life. Times added at the same instant are
+// weighted equally, so the average does not depend on how often times are added.
+type EWMAActionAverage struct {
+	halfLife   time.Duration
+	clock      Clock
+	actionData *safeEWMADatastore
+}
+
+// NewEWMAActionAverager creates a new ActionAverager that decays with the half-life, if clock is nil time.Now is
+// used
+func NewEWMAActionAverager(halfLife time.Duration, clock Clock) (ActionAverager, error) {
+	if halfLife <= 0 {
+		return nil, fmt.Errorf("half-life, %s, must be greater than 0", halfLife)
+	}
+	if clock == nil {
+		clock = time.Now
+	}
+
+	return &EWMAActionAverage{
+		halfLife: halfLife,
+		clock:    clock,
+		actionData: &safeEWMADatastore{
+			Data: make(map[string]*ewmaActionData),
+		},
+	}, nil
+}
+
+// AddAction takes a json serialized string and adds the action and time to the moving average of the action
+func (eav *EWMAActionAverage) AddAction(input string) error {
+	actStr, timeFlt, err := parseAction(input)
+	if err != nil {
+		return err
+	}
+
+	eav.addSample(actStr, timeFlt)
+	return nil
+}
+
+// AddSample adds the action and time, it accepts the same actions and times as AddAction
+func (eav *EWMAActionAverage) AddSample(actStr string, timeFlt float64) error {
+	if err := validateSample(actStr, timeFlt); err != nil {
+		return err
+	}
+
+	eav.addSample(actStr, timeFlt)
+	return nil
+}
+
+func (eav *EWMAActionAverage) addSample(actStr string, timeFlt float64) {
+	now := eav.clock()
+
+	eav.actionData.Mux.Lock()
+	defer eav.actionData.Mux.Unlock()
+
+	data, ok := eav.actionData.Data[actStr]
+	if ok {
+		// NOTE: the clock going backwards is treated as no time passing so weights never grow
+		elapsed := now.Sub(data.LastUpdate)
+		if elapsed > 0 {
+			decay := math.Exp2(-float64(elapsed) / float64(eav.halfLife))
+			data.WeightedTime *= decay
+			data.Weight *= decay
+			data.LastUpdate = now
+		}
+		data.WeightedTime += timeFlt
+		data.Weight++
+		data.CallCount++
+	} else {
+		ed := &ewmaActionData{
+			WeightedTime: timeFlt,
+			Weight:       1,
+			CallCount:    1,
+			LastUpdate:   now,
+		}
+		eav.actionData.Data[actStr] = ed
+	}
+}
+
+// GetStats computes the exponentially weighted moving average and the total count of times for each action
+// ordered by action name
+func (eav *EWMAActionAverage) GetStats() string {
+	eav.actionData.Mux.Lock()
+	defer eav.actionData.Mux.Unlock()
+
+	var output []actionOutputJSON
+	for action, data := range eav.actionData.Data {
+		// NOTE: future proofing against possible divide by 0, currently this should not be possible
+		if data.Weight <= 0 {
+			continue
+		}
+		// NOTE: decaying both sums to the current time would not change their ratio so it is skipped
+		item := &ewmaOutputJSON{
+			Action:  action,
+			Average: data.WeightedTime / data.Weight,
+			Count:   data.CallCount,
+		}
+		output = append(output, item)
+	}
+
+	return marshalSortedByAction(output)
+}
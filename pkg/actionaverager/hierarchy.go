@@ -0,0 +1,180 @@
+package actionaverager
+
+import (
+	"sort"
+	"strings"
+)
+
+// hierarchySeparator separates the segments of action names when hierarchy is enabled with WithHierarchy
+const hierarchySeparator = "."
+
+// ActionStatsTree is a node of the tree of action names, its stats are the roll up of the action with the full
+// name of the node and every action under it, e.g. "checkout.payment" rolls up "checkout.payment" and
+// "checkout.payment.authorize". Action is the full name of the node and Name is its last segment.
+type ActionStatsTree struct {
+	Name string `json:"name"`
+	ActionStats
+	Children []*ActionStatsTree `json:"children,omitempty"`
+}
+
+// statsTreeNode is a node of the tree while it is being built
+type statsTreeNode struct {
+	action   string
+	data     *actionData
+	children map[string]*statsTreeNode
+}
+
+// GetPrefixStats computes the same stats as GetStats rolled up over the action named prefix and every action under
+// it, it returns false if none of them have been added. The roll up is exact, it has the same stats as if every
+// time had been added to a single action. Without hierarchy enabled only the action named prefix is under prefix,
+// so it is the same as GetActionStats.
+func (acav *ActionAverage) GetPrefixStats(prefix string) (ActionStats, bool) {
+	var rollup *actionData
+	for _, data := range acav.copyRollups(func(action string) bool { return acav.hasPrefix(action, prefix) }) {
+		if rollup == nil {
+			rollup = data
+			continue
+		}
+		rollup.merge(data)
+	}
+
+	if rollup == nil || rollup.CallCount <= 0 {
+		return ActionStats{}, false
+	}
+	return computeActionStats(prefix, rollup, nil), true
+}
+
+// GetStatsTree computes the same stats as GetPrefixStats for every prefix of every action as a json tree like
+// [{"name":"checkout","action":"checkout",...,"children":[{"name":"payment","action":"checkout.payment",...}]}],
+// where the children of each node are ordered by name. Without hierarchy enabled every action is a node without
+// children.
+func (acav *ActionAverage) GetStatsTree() string {
+	tree := acav.GetStatsTreeList()
+	// Return an empty json array if tree is empty
+	if len(tree) == 0 {
+		return emptyArrayJSON
+	}
+	return marshalJSON(tree)
+}
+
+// GetStatsTreeList computes the same tree as GetStatsTree, but returns it as a slice of the root nodes instead of
+// json. An action without any non empty segments, which can only come from Merge or Restore, is not in the tree.
+func (acav *ActionAverage) GetStatsTreeList() []*ActionStatsTree {
+	root := &statsTreeNode{}
+	for action, data := range acav.copyRollups(nil) {
+		node := root
+		segments := acav.segments(action)
+		for i, segment := range segments {
+			child, ok := node.children[segment]
+			if !ok {
+				child = &statsTreeNode{
+					action: strings.Join(segments[:i+1], hierarchySeparator),
+				}
+				if node.children == nil {
+					node.children = make(map[string]*statsTreeNode)
+				}
+				node.children[segment] = child
+			}
+			// NOTE: the data of an action is merged into every node above it, so each node gets its own copy
+			if child.data == nil {
+				child.data = data.copy()
+			} else {
+				child.data.merge(data)
+			}
+			node = child
+		}
+	}
+	return root.buildChildren()
+}
+
+// buildChildren computes the stats of each child of the node and their children ordered by name
+func (node *statsTreeNode) buildChildren() []*ActionStatsTree {
+	if len(node.children) == 0 {
+		return nil
+	}
+
+	children := make([]*ActionStatsTree, 0, len(node.children))
+	for name, child := range node.children {
+		children = append(children, &ActionStatsTree{
+			Name:        name,
+			ActionStats: computeActionStats(child.action, child.data, nil),
+			Children:    child.buildChildren(),
+		})
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name < children[j].Name
+	})
+	return children
+}
+
+// copyRollups returns a copy of the roll up of every action that match returns true for, or every action if match
+// is nil, without its series. Shards are locked one at a time like GetStats and the copies are rolled up after
+// they are unlocked.
+func (acav *ActionAverage) copyRollups(match func(string) bool) map[string]*actionData {
+	rollups := make(map[string]*actionData)
+	for _, shard := range acav.actionData.Shards {
+		shard.copyRollups(rollups, match)
+	}
+	return rollups
+}
+
+func (shard *actionShard) copyRollups(rollups map[string]*actionData, match func(string) bool) {
+	shard.Mux.Lock()
+	defer shard.Mux.Unlock()
+
+	for action, data := range shard.Data {
+		// NOTE: future proofing against possible divide by 0, currently this should not be possible
+		if data.CallCount <= 0 || (match != nil && !match(action)) {
+			continue
+		}
+		rollup := *data
+		rollup.Sketch = data.Sketch.copy()
+		rollup.Series = nil
+		rollups[action] = &rollup
+	}
+}
+
+// hasPrefix returns true if the action is named prefix or is under prefix in the hierarchy, "checkout.pay" is not
+// a prefix of "checkout.payment" since it is not a whole segment
+func (acav *ActionAverage) hasPrefix(action string, prefix string) bool {
+	if action == prefix {
+		return true
+	}
+	if !acav.policy.hierarchical {
+		return false
+	}
+	if hasEmptySegment(action) {
+		action = strings.Join(acav.segments(action), hierarchySeparator)
+	}
+	return action == prefix || strings.HasPrefix(action, prefix+hierarchySeparator)
+}
+
+// segments returns the segments of the action name without empty segments, without hierarchy enabled the whole
+// name is one segment.
+// NOTE: names with empty segments are rejected with hierarchy enabled, but can still be merged or restored from
+// an averager without it, so they are placed in the hierarchy as if the empty segments were not there.
+func (acav *ActionAverage) segments(action string) []string {
+	if !acav.policy.hierarchical {
+		return []string{action}
+	}
+
+	segments := strings.Split(action, hierarchySeparator)
+	if !hasEmptySegment(action) {
+		return segments
+	}
+	nonEmpty := segments[:0]
+	for _, segment := range segments {
+		if segment != "" {
+			nonEmpty = append(nonEmpty, segment)
+		}
+	}
+	return nonEmpty
+}
+
+// hasEmptySegment returns true if the action name has a segment without any characters
+func hasEmptySegment(action string) bool {
+	return action == "" ||
+		strings.HasPrefix(action, hierarchySeparator) ||
+		strings.HasSuffix(action, hierarchySeparator) ||
+		strings.Contains(action, hierarchySeparator+hierarchySeparator)
+}
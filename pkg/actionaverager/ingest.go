@@ -0,0 +1,96 @@
+package actionaverager
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ingestInitBufSize = 4 * 1024
+	// maxIngestLineSize is the longest line IngestNDJSON can read, a longer line stops the ingestion
+	maxIngestLineSize = 1024 * 1024
+)
+
+// LineError is the error for a single rejected line of newline delimited json, lines are numbered from 1
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (le *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", le.Line, le.Err)
+}
+
+// Unwrap returns the error the line was rejected with
+func (le *LineError) Unwrap() error {
+	return le.Err
+}
+
+// IngestError reports every rejected line in order of line number, along with the error that stopped reading
+// the lines after them if there was one
+type IngestError struct {
+	Errors []*LineError
+	// ReadErr is the error reading from the reader, or nil if every line was read
+	ReadErr error
+}
+
+func (ie *IngestError) Error() string {
+	lineErrs := make([]string, len(ie.Errors))
+	for i := range ie.Errors {
+		lineErrs[i] = ie.Errors[i].Error()
+	}
+	msg := fmt.Sprintf("rejected %d lines, %s", len(ie.Errors), strings.Join(lineErrs, "; "))
+	if ie.ReadErr != nil {
+		msg += fmt.Sprintf(", then failed to read: %v", ie.ReadErr)
+	}
+	return msg
+}
+
+// Unwrap returns the read error so that errors.Is matches it, or nil if every line was read
+func (ie *IngestError) Unwrap() error {
+	return ie.ReadErr
+}
+
+// IngestNDJSON reads newline delimited json actions from reader one line at a time and adds each of them to
+// averager with AddAction, blank lines are skipped. It returns the number of actions added. If stopOnError is
+// true ingestion stops at the first rejected line, otherwise rejected lines are skipped and ingestion continues,
+// either way any rejected lines are reported in an *IngestError. Errors reading from reader stop the ingestion
+// and are returned as is if no lines were rejected, otherwise they are the ReadErr of the *IngestError.
+func IngestNDJSON(averager ActionAverager, reader io.Reader, stopOnError bool) (int, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, ingestInitBufSize), maxIngestLineSize)
+
+	numAdded := 0
+	lineNum := 0
+	ingestErr := &IngestError{}
+	for scanner.Scan() {
+		lineNum++
+		// NOTE: trim so that files with windows line endings and indented lines are handled
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if err := averager.AddAction(line); err != nil {
+			ingestErr.Errors = append(ingestErr.Errors, &LineError{Line: lineNum, Err: err})
+			if stopOnError {
+				break
+			}
+			continue
+		}
+		numAdded++
+	}
+	if err := scanner.Err(); err != nil {
+		if len(ingestErr.Errors) == 0 {
+			return numAdded, err
+		}
+		ingestErr.ReadErr = err
+	}
+
+	if len(ingestErr.Errors) == 0 {
+		return numAdded, nil
+	}
+	return numAdded, ingestErr
+}
@@ -0,0 +1,161 @@
+package actionaverager
+
+import (
+	"sort"
+)
+
+// maxLabels is the max number of labels a single action can have, along with the max number of label sets per
+// action it bounds the memory of an action
+const maxLabels = 16
+
+// AddLabeledSample adds the action, time and labels to the datastore, it accepts the same actions, times and labels
+// as AddAction. Labels with an empty value are dropped, so an empty value is the same as not having the label.
+// Labels are only kept when labels are enabled with WithLabels, otherwise they are ignored.
+func (acav *ActionAverage) AddLabeledSample(actStr string, timeFlt float64, labels map[string]string) error {
+	if err := acav.policy.validateSample(actStr, timeFlt); err != nil {
+		return acav.reject("", err)
+	}
+	if acav.policy.maxLabelSets <= 0 {
+		labels = nil
+	}
+	// NOTE: the labels are copied so the datastore never shares a map with the caller
+	labels, err := copyLabels(labels, "")
+	if err != nil {
+		return acav.reject("", err)
+	}
+
+	if !acav.addSample(actStr, timeFlt, 1, labels) {
+		return acav.reject("", newLabelSetLimitError(actStr, "", acav.policy.maxLabelSets))
+	}
+	return nil
+}
+
+// GetStatsGroupedBy computes the same stats as GetStats for each action broken down by the values of keys, the
+// stats of label sets with the same values for keys are rolled up together and every other label is ignored. The
+// labels of each group only have the keys with a value, so label sets without any of keys are rolled up into a
+// group without labels. With no keys every action is rolled up into one group, like GetStats. Groups are ordered
+// by action name and then by labels.
+func (acav *ActionAverage) GetStatsGroupedBy(keys ...string) string {
+	return marshalStats(acav.GetStatsGroupedByList(keys...))
+}
+
+// GetStatsGroupedByList computes the same stats as GetStatsGroupedBy, but returns them as a slice instead of json
+func (acav *ActionAverage) GetStatsGroupedByList(keys ...string) []ActionStats {
+	var output []ActionStats
+	for _, shard := range acav.actionData.Shards {
+		output = shard.appendGroupedStats(output, keys)
+	}
+
+	sort.Slice(output, func(i, j int) bool {
+		if output[i].Action != output[j].Action {
+			return output[i].Action < output[j].Action
+		}
+		return labelKey(output[i].Labels) < labelKey(output[j].Labels)
+	})
+	return output
+}
+
+// appendGroupedStats appends the stats of each group of each action in the shard to output
+func (shard *actionShard) appendGroupedStats(output []ActionStats, keys []string) []ActionStats {
+	shard.Mux.Lock()
+	defer shard.Mux.Unlock()
+
+	for action, data := range shard.Data {
+		// NOTE: future proofing against possible divide by 0, currently this should not be possible
+		if data.CallCount <= 0 {
+			continue
+		}
+		if len(data.Series) == 0 {
+			output = append(output, computeActionStats(action, data, nil))
+			continue
+		}
+
+		groups := make(map[string]*actionData)
+		for _, series := range data.Series {
+			groupLabels := selectLabels(series.Labels, keys)
+			key := labelKey(groupLabels)
+			group, ok := groups[key]
+			if !ok {
+				group = series.copy()
+				group.Labels = groupLabels
+				groups[key] = group
+				continue
+			}
+			group.merge(series)
+		}
+		for _, group := range groups {
+			stats := computeActionStats(action, group, nil)
+			stats.Labels = group.Labels
+			output = append(output, stats)
+		}
+	}
+	return output
+}
+
+// parseLabels validates the labels field of json input and returns the labels without empty values
+func parseLabels(rawLabels interface{}, input string) (map[string]string, error) {
+	labelsMap, ok := rawLabels.(map[string]interface{})
+	if !ok {
+		return nil, newValidationError(labelsKey, ErrWrongType, input, "labels field is not an object in input %s, rejecting", input)
+	}
+
+	labels := make(map[string]string, len(labelsMap))
+	for key, rawValue := range labelsMap {
+		value, ok := rawValue.(string)
+		if !ok {
+			return nil, newValidationError(labelsKey, ErrWrongType, input, "label %q is not a string in input %s, rejecting", key, input)
+		}
+		labels[key] = value
+	}
+	return copyLabels(labels, input)
+}
+
+// copyLabels validates labels and returns a copy of them without empty values, nil if there are not any
+func copyLabels(labels map[string]string, input string) (map[string]string, error) {
+	var labelsCopy map[string]string
+	for key, value := range labels {
+		if key == "" {
+			return nil, newValidationError(labelsKey, ErrMissingField, input, "label with value %q has an empty name, rejecting", value)
+		}
+		if value == "" {
+			continue
+		}
+		if labelsCopy == nil {
+			labelsCopy = make(map[string]string, len(labels))
+		}
+		labelsCopy[key] = value
+	}
+	if len(labelsCopy) > maxLabels {
+		return nil, newValidationError(labelsKey, ErrCardinalityLimit, input, "%d labels is more than the max %d labels, rejecting", len(labelsCopy), maxLabels)
+	}
+	return labelsCopy, nil
+}
+
+func newLabelSetLimitError(actStr string, input string, maxLabelSets int) error {
+	return newValidationError(labelsKey, ErrCardinalityLimit, input, "action %q already has the max %d label sets, rejecting", actStr, maxLabelSets)
+}
+
+// selectLabels returns the labels that have one of keys, nil if there are not any
+func selectLabels(labels map[string]string, keys []string) map[string]string {
+	var selected map[string]string
+	for _, key := range keys {
+		value, ok := labels[key]
+		if !ok {
+			continue
+		}
+		if selected == nil {
+			selected = make(map[string]string, len(keys))
+		}
+		selected[key] = value
+	}
+	return selected
+}
+
+// labelKey returns the same key for the same labels in any order, json is used since go marshals maps with
+// sorted keys and escapes every name and value so different labels never have the same key
+func labelKey(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	return marshalJSON(labels)
+}
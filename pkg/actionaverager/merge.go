@@ -0,0 +1,145 @@
+package actionaverager
+
+import (
+	"io"
+	"math"
+	"sync/atomic"
+)
+
+// Merge adds the state of every action in other to the averager, the result is the same as if every action added
+// to other had been added to the averager as well. Averages, counts, sums, mins, maxes and variances are merged
+// exactly, up to floating point rounding, and quantile sketches are merged by adding their bins, so no averages
+// of averages are taken. other is not changed.
+func (acav *ActionAverage) Merge(other *ActionAverage) {
+	// NOTE: other is copied under its own lock before the averager is locked, so merging two averagers into each
+	// other at the same time can not deadlock and merging an averager into itself doubles it
+	acav.mergeData(other.copyData())
+}
+
+// MergeSnapshot adds the state of every action in a snapshot written by Snapshot to the averager, like Merge. If
+// the snapshot is invalid the state is left unchanged.
+func (acav *ActionAverage) MergeSnapshot(reader io.Reader) error {
+	data, err := readSnapshot(reader)
+	if err != nil {
+		return err
+	}
+
+	acav.mergeData(data)
+	return nil
+}
+
+// MergeSnapshots merges every snapshot read from readers into a single snapshot written to writer, so snapshots
+// from many averagers can be combined without keeping an averager around. Label sets are kept without a limit,
+// since they were already accepted by the averagers that wrote the snapshots. If any snapshot is invalid nothing is
+// written.
+func MergeSnapshots(writer io.Writer, readers ...io.Reader) error {
+	options := newAveragerOptions()
+	options.policy.maxLabelSets = math.MaxInt32
+	merged := newActionAverage(options)
+	for _, reader := range readers {
+		if err := merged.MergeSnapshot(reader); err != nil {
+			return err
+		}
+	}
+	return merged.Snapshot(writer)
+}
+
+// copyData returns a deep copy of every action in the datastore, so it can be read after the datastore is unlocked
+func (acav *ActionAverage) copyData() map[string]*actionData {
+	acav.actionData.lockAll()
+	defer acav.actionData.unlockAll()
+
+	data := make(map[string]*actionData)
+	for _, shard := range acav.actionData.Shards {
+		for action, ad := range shard.Data {
+			data[action] = ad.copy()
+		}
+	}
+	return data
+}
+
+// mergeData merges data, which must not be shared with any datastore, into the datastore. Every shard is locked
+// so that the whole merge is seen at once.
+func (acav *ActionAverage) mergeData(data map[string]*actionData) {
+	acav.normalizeSeries(data)
+
+	acav.actionData.lockAll()
+	defer acav.actionData.unlockAll()
+
+	for action, ad := range data {
+		shard := acav.actionData.shard(action)
+		atomic.AddUint64(&shard.Version, 1)
+		existing, ok := shard.Data[action]
+		if !ok {
+			// NOTE: data is not shared, so it can be stored without another copy
+			shard.Data[action] = ad
+			continue
+		}
+		existing.merge(ad)
+	}
+}
+
+// copy returns a deep copy of the action data and its series, labels are never changed so they are shared
+func (ad *actionData) copy() *actionData {
+	cp := *ad
+	cp.Sketch = ad.Sketch.copy()
+	if ad.Series != nil {
+		cp.Series = make(map[string]*actionData, len(ad.Series))
+		for key, series := range ad.Series {
+			cp.Series[key] = series.copy()
+		}
+	}
+	return &cp
+}
+
+// merge adds other to the action data, the running mean and sum of squared differences are combined with Chan's
+// parallel form of Welford's algorithm. Series of other that the action data does not have are stored without a
+// copy, so other must not be shared with a datastore if it has series. Merging does not check the label set limit,
+// since the merged data was already accepted.
+func (ad *actionData) merge(other *actionData) {
+	if other.CallCount <= 0 {
+		return
+	}
+
+	count := ad.CallCount + other.CallCount
+	delta := other.MeanTime - ad.MeanTime
+	ad.MeanTime += delta * other.CallCount / count
+	ad.SqDiffSum += other.SqDiffSum + delta*delta*ad.CallCount*other.CallCount/count
+	ad.CallCount = count
+	ad.TotalTime += other.TotalTime
+	if other.MinTime < ad.MinTime {
+		ad.MinTime = other.MinTime
+	}
+	if other.MaxTime > ad.MaxTime {
+		ad.MaxTime = other.MaxTime
+	}
+	ad.Sketch.merge(other.Sketch)
+
+	for key, series := range other.Series {
+		existing, ok := ad.Series[key]
+		if ok {
+			existing.merge(series)
+			continue
+		}
+		if ad.Series == nil {
+			ad.Series = make(map[string]*actionData)
+		}
+		ad.Series[key] = series
+	}
+}
+
+// normalizeSeries makes data, which must not be shared with any datastore, have series only if the averager has
+// labels enabled. Actions without series get a single series without labels, so the series of an action always add
+// up to the action.
+func (acav *ActionAverage) normalizeSeries(data map[string]*actionData) {
+	for _, ad := range data {
+		if acav.policy.maxLabelSets <= 0 {
+			ad.Series = nil
+			continue
+		}
+		if len(ad.Series) == 0 {
+			series := ad.copy()
+			ad.Series = map[string]*actionData{labelKey(nil): series}
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package actionaverager
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ZeroTimePolicy is what an ActionAverage does with a time of 0
+type ZeroTimePolicy int
+
+const (
+	// ZeroTimeCount adds times of 0 like any other time
+	ZeroTimeCount ZeroTimePolicy = iota
+	// ZeroTimeIgnore accepts times of 0 without adding them, so they do not change any stats
+	ZeroTimeIgnore
+	// ZeroTimeReject rejects times of 0 with ErrZeroTime
+	ZeroTimeReject
+)
+
+// validationPolicy is the rules an ActionAverage accepts actions with on top of the rules every averager has, the
+// zero value is the default strict policy
+type validationPolicy struct {
+	lenientFields  bool
+	hasTimeRange   bool
+	minTime        float64
+	maxTime        float64
+	maxActionLen   int
+	actionPattern  *regexp.Regexp
+	allowedActions map[string]struct{}
+	zeroTime       ZeroTimePolicy
+	maxLabelSets   int
+	hierarchical   bool
+}
+
+// defaultPolicy is the policy of NewActionAverager and every other averager
+var defaultPolicy = validationPolicy{}
+
+// averagerOptions is everything an Option can configure
+type averagerOptions struct {
+	policy      validationPolicy
+	deadLetters DeadLetterSink
+	clock       Clock
+}
+
+func newAveragerOptions() *averagerOptions {
+	return &averagerOptions{
+		policy: defaultPolicy,
+		clock:  time.Now,
+	}
+}
+
+// Option configures an ActionAverage created with NewActionAveragerWithOptions
+type Option func(*averagerOptions) error
+
+// WithLenientFields accepts input with fields other than "action" and "time", like a "ts" or "host" field, and
+// ignores them. By default input with any other field is rejected.
+func WithLenientFields() Option {
+	return func(opts *averagerOptions) error {
+		opts.policy.lenientFields = true
+		return nil
+	}
+}
+
+// WithTimeRange rejects times less than minTime or greater than maxTime with ErrTimeOutOfRange, minTime can not be
+// less than 0 since negative times are always rejected
+func WithTimeRange(minTime float64, maxTime float64) Option {
+	return func(opts *averagerOptions) error {
+		// NOTE: negated comparison so that NaN is rejected as well
+		if !(minTime >= 0 && maxTime >= minTime) {
+			return fmt.Errorf("time range %v to %v must be from at least 0 to at least the min time", minTime, maxTime)
+		}
+		opts.policy.hasTimeRange = true
+		opts.policy.minTime = minTime
+		opts.policy.maxTime = maxTime
+		return nil
+	}
+}
+
+// WithMaxActionLength rejects action names longer than maxLen bytes with ErrActionTooLong
+func WithMaxActionLength(maxLen int) Option {
+	return func(opts *averagerOptions) error {
+		if maxLen <= 0 {
+			return fmt.Errorf("max action length, %d, must be greater than 0", maxLen)
+		}
+		opts.policy.maxActionLen = maxLen
+		return nil
+	}
+}
+
+// WithActionPattern rejects action names that do not match pattern with ErrActionNotAllowed, the pattern matches
+// anywhere in the name unless it is anchored with ^ and $
+func WithActionPattern(pattern *regexp.Regexp) Option {
+	return func(opts *averagerOptions) error {
+		if pattern == nil {
+			return fmt.Errorf("action pattern must not be nil")
+		}
+		opts.policy.actionPattern = pattern
+		return nil
+	}
+}
+
+// WithAllowedActions rejects action names that are not one of actions with ErrActionNotAllowed
+func WithAllowedActions(actions ...string) Option {
+	return func(opts *averagerOptions) error {
+		if len(actions) == 0 {
+			return fmt.Errorf("allowed actions must not be empty")
+		}
+		opts.policy.allowedActions = make(map[string]struct{}, len(actions))
+		for _, action := range actions {
+			opts.policy.allowedActions[action] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithLabels accepts an optional "labels" field of string values, like
+// {"action":"jump","time":10,"labels":{"region":"eu"}}, and keeps stats for each label set of an action that can
+// be queried with GetStatsGroupedBy. A new label set for an action that already has maxLabelSets label sets is
+// rejected with ErrCardinalityLimit, as are actions with more than 16 labels. Without this option input with
+// labels is rejected like any other extra field.
+func WithLabels(maxLabelSets int) Option {
+	return func(opts *averagerOptions) error {
+		if maxLabelSets <= 0 {
+			return fmt.Errorf("max label sets, %d, must be greater than 0", maxLabelSets)
+		}
+		opts.policy.maxLabelSets = maxLabelSets
+		return nil
+	}
+}
+
+// WithHierarchy treats action names as "." separated paths like "checkout.payment.authorize", so GetPrefixStats
+// and GetStatsTree roll up every action under a prefix like "checkout.payment". Action names with an empty segment,
+// like "checkout..authorize" or ".checkout", are rejected with ErrActionNotAllowed.
+func WithHierarchy() Option {
+	return func(opts *averagerOptions) error {
+		opts.policy.hierarchical = true
+		return nil
+	}
+}
+
+// WithDeadLetterSink records every rejected action in sink, see DeadLetterSink
+func WithDeadLetterSink(sink DeadLetterSink) Option {
+	return func(opts *averagerOptions) error {
+		if sink == nil {
+			return fmt.Errorf("dead letter sink must not be nil")
+		}
+		opts.deadLetters = sink
+		return nil
+	}
+}
+
+// WithClock sets the clock that dead letters are timestamped with, if clock is nil time.Now is used
+func WithClock(clock Clock) Option {
+	return func(opts *averagerOptions) error {
+		if clock == nil {
+			clock = time.Now
+		}
+		opts.clock = clock
+		return nil
+	}
+}
+
+// WithZeroTimePolicy sets what is done with a time of 0, by default it is added like any other time
+func WithZeroTimePolicy(policy ZeroTimePolicy) Option {
+	return func(opts *averagerOptions) error {
+		if policy != ZeroTimeCount && policy != ZeroTimeIgnore && policy != ZeroTimeReject {
+			return fmt.Errorf("unknown zero time policy %d", policy)
+		}
+		opts.policy.zeroTime = policy
+		return nil
+	}
+}
+
+// NewActionAveragerWithOptions creates a new ActionAverager with options that change which actions it accepts,
+// without any options it accepts exactly the same actions as NewActionAverager
+func NewActionAveragerWithOptions(opts ...Option) (ActionAverager, error) {
+	options := newAveragerOptions()
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+	return newActionAverage(options), nil
+}
+
+// check validates an action and time against the configurable rules, after they have passed the rules every
+// averager has. input is only used for the error and is empty for actions that were not json.
+func (vp *validationPolicy) check(actStr string, timeFlt float64, input string) error {
+	if vp.maxActionLen > 0 && len(actStr) > vp.maxActionLen {
+		return newValidationError(actKey, ErrActionTooLong, input, "action %q is longer than the max length %d, rejecting", actStr, vp.maxActionLen)
+	}
+	if vp.hierarchical && hasEmptySegment(actStr) {
+		return newValidationError(actKey, ErrActionNotAllowed, input, "action %q has an empty segment, rejecting", actStr)
+	}
+	if vp.allowedActions != nil {
+		if _, ok := vp.allowedActions[actStr]; !ok {
+			return newValidationError(actKey, ErrActionNotAllowed, input, "action %q is not an allowed action, rejecting", actStr)
+		}
+	}
+	if vp.actionPattern != nil && !vp.actionPattern.MatchString(actStr) {
+		return newValidationError(actKey, ErrActionNotAllowed, input, "action %q does not match the action pattern %s, rejecting", actStr, vp.actionPattern)
+	}
+
+	if timeFlt == 0 && vp.zeroTime == ZeroTimeReject {
+		return newValidationError(timeKey, ErrZeroTime, input, "zero time value for action %q, rejecting", actStr)
+	}
+	// NOTE: a time of 0 that is ignored is not out of range, since it is never added
+	if vp.hasTimeRange && !vp.ignore(timeFlt) && (timeFlt < vp.minTime || timeFlt > vp.maxTime) {
+		return newValidationError(timeKey, ErrTimeOutOfRange, input, "time value %v for action %q is not between %v and %v, rejecting", timeFlt, actStr, vp.minTime, vp.maxTime)
+	}
+	return nil
+}
+
+// ignore returns true for a valid time that is accepted but not added
+func (vp *validationPolicy) ignore(timeFlt float64) bool {
+	return timeFlt == 0 && vp.zeroTime == ZeroTimeIgnore
+}
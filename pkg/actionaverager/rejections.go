@@ -0,0 +1,92 @@
+package actionaverager
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// otherReason is the reason rejections that are not a ValidationError, like a batch that is not a json array, are
+// counted under
+const otherReason = "other"
+
+// rejectionReasons are the reasons rejections are counted by, in the order of their counters. The counter after
+// the last reason is for otherReason.
+var rejectionReasons = []struct {
+	name   string
+	reason error
+}{
+	{"invalid_json", ErrInvalidJSON},
+	{"not_object", ErrNotObject},
+	{"field_count", ErrFieldCount},
+	{"missing_field", ErrMissingField},
+	{"wrong_type", ErrWrongType},
+	{"negative_time", ErrNegativeTime},
+	{"non_finite_time", ErrNonFiniteTime},
+	{"time_out_of_range", ErrTimeOutOfRange},
+	{"zero_time", ErrZeroTime},
+	{"action_too_long", ErrActionTooLong},
+	{"action_not_allowed", ErrActionNotAllowed},
+	{"cardinality_limit", ErrCardinalityLimit},
+}
+
+// RejectionStats are the number of rejected actions, in total and by reason. Reasons are named after their
+// sentinel errors, e.g. "missing_field" for ErrMissingField, and only reasons with rejections are included.
+type RejectionStats struct {
+	Total   uint64            `json:"total"`
+	Reasons map[string]uint64 `json:"reasons"`
+}
+
+// GetRejectionStats returns the number of actions that have been rejected by reason as json like
+// {"total":3,"reasons":{"missing_field":2,"negative_time":1}}, separately from GetStats
+func (acav *ActionAverage) GetRejectionStats() string {
+	return marshalJSON(acav.GetRejections())
+}
+
+// GetRejections returns the same counts as GetRejectionStats as a RejectionStats
+func (acav *ActionAverage) GetRejections() RejectionStats {
+	stats := RejectionStats{
+		Reasons: make(map[string]uint64),
+	}
+	for i := range acav.rejections {
+		count := atomic.LoadUint64(&acav.rejections[i])
+		if count == 0 {
+			continue
+		}
+		stats.Total += count
+		stats.Reasons[reasonName(i)] = count
+	}
+	return stats
+}
+
+// reject counts err under its reason and records input in the dead letter sink, if there is one, it returns err so
+// callers can return it directly
+func (acav *ActionAverage) reject(input string, err error) error {
+	index := reasonIndex(err)
+	atomic.AddUint64(&acav.rejections[index], 1)
+
+	if acav.deadLetters != nil {
+		acav.deadLetters.Record(&DeadLetter{
+			Time:   acav.clock(),
+			Input:  input,
+			Reason: reasonName(index),
+			Error:  err.Error(),
+		})
+	}
+	return err
+}
+
+func reasonIndex(err error) int {
+	for i := range rejectionReasons {
+		if errors.Is(err, rejectionReasons[i].reason) {
+			return i
+		}
+	}
+	return len(rejectionReasons)
+}
+
+func reasonName(index int) string {
+	if index < len(rejectionReasons) {
+		return rejectionReasons[index].name
+	}
+	return otherReason
+}
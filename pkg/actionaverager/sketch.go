@@ -0,0 +1,133 @@
+package actionaverager
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// sketchRelativeAccuracy is the relative error bound of quantile estimates made by a quantileSketch
+	sketchRelativeAccuracy = 0.01
+	// sketchMaxBins bounds the memory used by a quantileSketch, with the default accuracy this covers
+	// values that span roughly 35 orders of magnitude before any bins have to be collapsed
+	sketchMaxBins = 2048
+	// sketchMinValue is the smallest value that gets its own bin, anything smaller is counted as 0
+	sketchMinValue = 1e-9
+)
+
+var (
+	sketchGamma    = (1 + sketchRelativeAccuracy) / (1 - sketchRelativeAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// quantileSketch is a DDSketch style quantile sketch. Values are counted in logarithmically sized bins
+// so that any quantile estimate is within sketchRelativeAccuracy of the true value, as long as the
+// values fit in sketchMaxBins bins. When there are more bins than that the lowest bins are collapsed
+// together, which only affects the accuracy of the lowest quantiles. Sketches with the same accuracy
+// can be merged exactly by adding their bin counts.
+type quantileSketch struct {
+	Bins      map[int]float64
+	ZeroCount float64
+	Count     float64
+}
+
+func newQuantileSketch() *quantileSketch {
+	return &quantileSketch{
+		Bins: make(map[int]float64),
+	}
+}
+
+func sketchIndex(value float64) int {
+	return int(math.Ceil(math.Log(value) / sketchLogGamma))
+}
+
+// sketchValue returns the value for a bin that is within sketchRelativeAccuracy of every value in the bin
+func sketchValue(index int) float64 {
+	return 2 * math.Exp(float64(index)*sketchLogGamma) / (1 + sketchGamma)
+}
+
+// add counts a single non negative value in the sketch
+func (qs *quantileSketch) add(value float64, weight float64) {
+	qs.Count += weight
+	if value < sketchMinValue {
+		qs.ZeroCount += weight
+		return
+	}
+
+	qs.Bins[sketchIndex(value)] += weight
+	if len(qs.Bins) > sketchMaxBins {
+		qs.collapse()
+	}
+}
+
+func (qs *quantileSketch) copy() *quantileSketch {
+	cp := &quantileSketch{
+		Bins:      make(map[int]float64, len(qs.Bins)),
+		ZeroCount: qs.ZeroCount,
+		Count:     qs.Count,
+	}
+	for index, count := range qs.Bins {
+		cp.Bins[index] = count
+	}
+	return cp
+}
+
+// merge adds all of the counts from other into the sketch
+func (qs *quantileSketch) merge(other *quantileSketch) {
+	qs.Count += other.Count
+	qs.ZeroCount += other.ZeroCount
+	for index, count := range other.Bins {
+		qs.Bins[index] += count
+	}
+	if len(qs.Bins) > sketchMaxBins {
+		qs.collapse()
+	}
+}
+
+// collapse folds the lowest bins into each other until the sketch is back under sketchMaxBins bins
+func (qs *quantileSketch) collapse() {
+	indexes := qs.sortedIndexes()
+	numCollapse := len(indexes) - sketchMaxBins
+	target := indexes[numCollapse]
+	for _, index := range indexes[:numCollapse] {
+		qs.Bins[target] += qs.Bins[index]
+		delete(qs.Bins, index)
+	}
+}
+
+func (qs *quantileSketch) sortedIndexes() []int {
+	indexes := make([]int, 0, len(qs.Bins))
+	for index := range qs.Bins {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// quantiles estimates each of the quantiles, which must be between 0 and 1, the returned estimates are in
+// the same order as the quantiles provided
+func (qs *quantileSketch) quantiles(quantiles []float64) []float64 {
+	estimates := make([]float64, len(quantiles))
+	if qs.Count <= 0 {
+		return estimates
+	}
+
+	indexes := qs.sortedIndexes()
+	for i, quantile := range quantiles {
+		// NOTE: rank is the 0 based position of the value in the sorted values, the estimate is for the
+		// value at the floor of the rank so no interpolation between values is done
+		rank := quantile * (qs.Count - 1)
+		seen := qs.ZeroCount
+		if seen > rank {
+			continue
+		}
+		for _, index := range indexes {
+			seen += qs.Bins[index]
+			if seen > rank {
+				estimates[i] = sketchValue(index)
+				break
+			}
+		}
+	}
+	return estimates
+}
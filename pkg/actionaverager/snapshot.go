@@ -0,0 +1,239 @@
+package actionaverager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+)
+
+// snapshotVersion is the version of the snapshot format written by Snapshot, Restore rejects any other version
+const snapshotVersion = 1
+
+// Snapshotter is an ActionAverager whose state can be saved and restored
+type Snapshotter interface {
+	ActionAverager
+	Snapshot(io.Writer) error
+	Restore(io.Reader) error
+}
+
+// NOTE: the snapshot stores the running totals and not derived stats like averages, so restoring continues the
+// exact same running stats. Go marshals float64s with the fewest digits that parse back to the same float64, so
+// json does not lose any precision.
+type snapshotJSON struct {
+	Version int                   `json:"version"`
+	Actions []*snapshotActionJSON `json:"actions"`
+}
+
+type snapshotActionJSON struct {
+	Action string `json:"action"`
+	snapshotDataJSON
+	Series []*snapshotSeriesJSON `json:"series,omitempty"`
+}
+
+// snapshotSeriesJSON is a label set of an action, it is only written for averagers with labels enabled
+type snapshotSeriesJSON struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	snapshotDataJSON
+}
+
+type snapshotDataJSON struct {
+	TotalTime float64             `json:"total_time"`
+	CallCount float64             `json:"call_count"`
+	MinTime   float64             `json:"min_time"`
+	MaxTime   float64             `json:"max_time"`
+	MeanTime  float64             `json:"mean_time"`
+	SqDiffSum float64             `json:"sq_diff_sum"`
+	Sketch    *snapshotSketchJSON `json:"sketch"`
+}
+
+type snapshotSketchJSON struct {
+	Bins      map[int]float64 `json:"bins"`
+	ZeroCount float64         `json:"zero_count"`
+	Count     float64         `json:"count"`
+}
+
+// Snapshot writes the state of every action to writer as versioned json that Restore can read
+func (acav *ActionAverage) Snapshot(writer io.Writer) error {
+	snapshot := acav.buildSnapshot()
+
+	// NOTE: the datastore is not locked while writing, since writing can be slow
+	return json.NewEncoder(writer).Encode(snapshot)
+}
+
+func (acav *ActionAverage) buildSnapshot() *snapshotJSON {
+	// NOTE: every shard is locked so the snapshot is of a single moment across every action
+	acav.actionData.lockAll()
+	defer acav.actionData.unlockAll()
+
+	snapshot := &snapshotJSON{
+		Version: snapshotVersion,
+		Actions: make([]*snapshotActionJSON, 0),
+	}
+	for _, shard := range acav.actionData.Shards {
+		for action, data := range shard.Data {
+			item := &snapshotActionJSON{
+				Action:           action,
+				snapshotDataJSON: newSnapshotData(data),
+			}
+			// NOTE: an action whose only series has no labels is written without series, Restore and Merge
+			// recreate it, so snapshots of unlabeled actions are the same with or without labels enabled
+			if len(data.Series) == 1 && data.Series[labelKey(nil)] != nil {
+				snapshot.Actions = append(snapshot.Actions, item)
+				continue
+			}
+			for _, series := range data.Series {
+				item.Series = append(item.Series, &snapshotSeriesJSON{
+					Labels:           series.Labels,
+					snapshotDataJSON: newSnapshotData(series),
+				})
+			}
+			sort.Slice(item.Series, func(i, j int) bool {
+				return labelKey(item.Series[i].Labels) < labelKey(item.Series[j].Labels)
+			})
+			snapshot.Actions = append(snapshot.Actions, item)
+		}
+	}
+	// NOTE: sorted so the same state always gives the same snapshot
+	sort.Slice(snapshot.Actions, func(i, j int) bool {
+		return snapshot.Actions[i].Action < snapshot.Actions[j].Action
+	})
+	return snapshot
+}
+
+func newSnapshotData(data *actionData) snapshotDataJSON {
+	bins := make(map[int]float64, len(data.Sketch.Bins))
+	for index, count := range data.Sketch.Bins {
+		bins[index] = count
+	}
+	return snapshotDataJSON{
+		TotalTime: data.TotalTime,
+		CallCount: data.CallCount,
+		MinTime:   data.MinTime,
+		MaxTime:   data.MaxTime,
+		MeanTime:  data.MeanTime,
+		SqDiffSum: data.SqDiffSum,
+		Sketch: &snapshotSketchJSON{
+			Bins:      bins,
+			ZeroCount: data.Sketch.ZeroCount,
+			Count:     data.Sketch.Count,
+		},
+	}
+}
+
+// Restore replaces the state of every action with a snapshot written by Snapshot, if the snapshot is invalid the
+// state is left unchanged
+func (acav *ActionAverage) Restore(reader io.Reader) error {
+	data, err := readSnapshot(reader)
+	if err != nil {
+		return err
+	}
+	acav.normalizeSeries(data)
+
+	shardData := make([]map[string]*actionData, numShards)
+	for i := range shardData {
+		shardData[i] = make(map[string]*actionData)
+	}
+	for action, ad := range data {
+		shardData[shardIndex(action)][action] = ad
+	}
+
+	acav.actionData.lockAll()
+	defer acav.actionData.unlockAll()
+
+	for i, shard := range acav.actionData.Shards {
+		shard.Data = shardData[i]
+		atomic.AddUint64(&shard.Version, 1)
+	}
+	return nil
+}
+
+// readSnapshot reads and validates a snapshot into a new datastore map
+func readSnapshot(reader io.Reader) (map[string]*actionData, error) {
+	var snapshot snapshotJSON
+	if err := json.NewDecoder(reader).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d, expect %d, rejecting", snapshot.Version, snapshotVersion)
+	}
+
+	data := make(map[string]*actionData, len(snapshot.Actions))
+	for _, item := range snapshot.Actions {
+		if item == nil {
+			return nil, fmt.Errorf("snapshot is missing action data, rejecting")
+		}
+		if _, ok := data[item.Action]; ok {
+			return nil, fmt.Errorf("duplicate action %s in snapshot, rejecting", item.Action)
+		}
+		ad, err := item.snapshotDataJSON.actionData(item.Action)
+		if err != nil {
+			return nil, err
+		}
+
+		var seriesCount float64
+		for _, series := range item.Series {
+			if series == nil {
+				return nil, fmt.Errorf("snapshot is missing series data for action %s, rejecting", item.Action)
+			}
+			labels, err := copyLabels(series.Labels, "")
+			if err != nil || len(labels) != len(series.Labels) {
+				return nil, fmt.Errorf("invalid labels for action %s in snapshot, rejecting", item.Action)
+			}
+			seriesData, err := series.snapshotDataJSON.actionData(item.Action)
+			if err != nil {
+				return nil, err
+			}
+			seriesData.Labels = labels
+
+			key := labelKey(labels)
+			if _, ok := ad.Series[key]; ok {
+				return nil, fmt.Errorf("duplicate labels for action %s in snapshot, rejecting", item.Action)
+			}
+			if ad.Series == nil {
+				ad.Series = make(map[string]*actionData, len(item.Series))
+			}
+			ad.Series[key] = seriesData
+			seriesCount += seriesData.CallCount
+		}
+		// NOTE: the action is the roll up of its series, so they must have the same count
+		if ad.Series != nil && seriesCount != ad.CallCount {
+			return nil, fmt.Errorf("series counts do not add up to the count of action %s in snapshot, rejecting", item.Action)
+		}
+		data[item.Action] = ad
+	}
+	return data, nil
+}
+
+// actionData validates the snapshot data of action and converts it to action data
+func (sd *snapshotDataJSON) actionData(action string) (*actionData, error) {
+	if sd.Sketch == nil {
+		return nil, fmt.Errorf("snapshot is missing sketch data for action %s, rejecting", action)
+	}
+	if sd.CallCount <= 0 || sd.Sketch.Count != sd.CallCount {
+		return nil, fmt.Errorf("invalid count for action %s in snapshot, rejecting", action)
+	}
+	if sd.TotalTime < 0 || sd.MinTime < 0 || sd.MinTime > sd.MaxTime || sd.SqDiffSum < 0 {
+		return nil, fmt.Errorf("invalid times for action %s in snapshot, rejecting", action)
+	}
+	if len(sd.Sketch.Bins) > sketchMaxBins {
+		return nil, fmt.Errorf("too many sketch bins for action %s in snapshot, rejecting", action)
+	}
+
+	sketch := newQuantileSketch()
+	for index, count := range sd.Sketch.Bins {
+		sketch.Bins[index] = count
+	}
+	sketch.ZeroCount = sd.Sketch.ZeroCount
+	sketch.Count = sd.Sketch.Count
+	return &actionData{
+		TotalTime: sd.TotalTime,
+		CallCount: sd.CallCount,
+		MinTime:   sd.MinTime,
+		MaxTime:   sd.MaxTime,
+		MeanTime:  sd.MeanTime,
+		SqDiffSum: sd.SqDiffSum,
+		Sketch:    sketch,
+	}, nil
+}
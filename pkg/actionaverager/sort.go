@@ -0,0 +1,63 @@
+package actionaverager
+
+import (
+	"sort"
+)
+
+// SortKey is the stat that stats are ordered by
+type SortKey int
+
+// SortOrder is the direction that stats are ordered in
+type SortOrder int
+
+const (
+	// SortByAction orders stats by action name
+	SortByAction SortKey = iota
+	// SortByAverage orders stats by average time
+	SortByAverage
+	// SortByCount orders stats by number of times added
+	SortByCount
+)
+
+const (
+	// Ascending orders stats from the smallest to the largest value
+	Ascending SortOrder = iota
+	// Descending orders stats from the largest to the smallest value
+	Descending
+)
+
+// GetStatsSorted computes the same stats as GetStats ordered by key in order, actions with equal values are
+// ordered by action name so the output is always the same for the same stats
+func (acav *ActionAverage) GetStatsSorted(key SortKey, order SortOrder) string {
+	output := acav.computeStats(nil)
+	sortStats(output, key, order)
+	return marshalStats(output)
+}
+
+// sortStats orders stats by key in order, an unknown key orders stats by action name
+func sortStats(stats []ActionStats, key SortKey, order SortOrder) {
+	if key != SortByAverage && key != SortByCount {
+		key = SortByAction
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		var lhs, rhs float64
+		switch key {
+		case SortByAverage:
+			lhs, rhs = stats[i].Average, stats[j].Average
+		case SortByCount:
+			lhs, rhs = stats[i].Count, stats[j].Count
+		}
+		if order == Descending {
+			lhs, rhs = rhs, lhs
+		}
+		if lhs != rhs {
+			return lhs < rhs
+		}
+
+		// NOTE: action names are unique so this makes the order total
+		if order == Descending && key == SortByAction {
+			return stats[i].Action > stats[j].Action
+		}
+		return stats[i].Action < stats[j].Action
+	})
+}
@@ -0,0 +1,76 @@
+package actionaverager
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultSubscribeInterval is the interval used by Subscribe when the interval given is not greater than 0
+const DefaultSubscribeInterval = time.Second
+
+// StatsSubscriber is an ActionAverager that can notify subscribers when its stats change
+type StatsSubscriber interface {
+	ActionAverager
+	Subscribe(time.Duration) (<-chan string, func())
+}
+
+// Subscribe returns a channel that receives the current stats, as returned by GetStats, right away and then again
+// whenever they have changed. Changes are checked for once every interval, so any number of changes within an
+// interval are coalesced into a single update. The channel only holds the latest stats, so a slow subscriber
+// skips stale stats instead of ever blocking AddAction. The returned func unsubscribes and closes the channel, it
+// is safe to call more than once.
+func (acav *ActionAverage) Subscribe(interval time.Duration) (<-chan string, func()) {
+	if interval <= 0 {
+		interval = DefaultSubscribeInterval
+	}
+
+	statsCh := make(chan string, 1)
+	done := make(chan struct{})
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+
+	go func() {
+		defer close(statsCh)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// NOTE: load the version before getting the stats so a change in between is sent on the next tick
+		lastVersion := acav.actionData.version()
+		sendLatest(statsCh, acav.GetStats())
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				version := acav.actionData.version()
+				if version == lastVersion {
+					continue
+				}
+				lastVersion = version
+				sendLatest(statsCh, acav.GetStats())
+			}
+		}
+	}()
+
+	return statsCh, unsubscribe
+}
+
+// sendLatest replaces anything the subscriber has not received yet with stats, this never blocks since this is
+// the only sender on the channel
+func sendLatest(statsCh chan string, stats string) {
+	select {
+	case statsCh <- stats:
+		return
+	default:
+	}
+
+	select {
+	case <-statsCh:
+	default:
+	}
+	statsCh <- stats
+}
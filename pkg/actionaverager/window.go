@@ -0,0 +1,183 @@
+package actionaverager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Clock returns the current time, averagers that depend on time take a Clock so tests can control time
+type Clock func() time.Time
+
+// basicOutputJSON is the output of averagers that do not track variance or quantiles
+type basicOutputJSON struct {
+	Action  string  `json:"action"`
+	Average float64 `json:"avg"`
+	Count   float64 `json:"count"`
+	Sum     float64 `json:"sum"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+}
+
+func (bo *basicOutputJSON) actionName() string {
+	return bo.Action
+}
+
+// NOTE: Epoch is the number of bucket widths since the unix epoch of the times in the bucket, a bucket with an
+// old epoch is stale and is reset when it is reused
+type windowBucket struct {
+	Epoch     int64
+	TotalTime float64
+	CallCount float64
+	MinTime   float64
+	MaxTime   float64
+}
+
+type windowActionData struct {
+	Buckets []windowBucket
+}
+
+type safeWindowDatastore struct {
+	Mux  sync.Mutex
+	Data map[string]*windowActionData
+}
+
+// WindowActionAverage implements the ActionAverager interface by averaging only the times added within a
+// trailing window. The window is split into a ring of buckets per action so memory stays bounded, which means
+// the window moves forward one bucket at a time, a time is included for at least window - window/numBuckets
+// and at most window after it is added.
+type WindowActionAverage struct {
+	bucketWidth int64
+	numBuckets  int64
+	clock       Clock
+	actionData  *safeWindowDatastore
+}
+
+// NewWindowActionAverager creates a new ActionAverager that averages over the trailing window using numBuckets
+// buckets, if clock is nil time.Now is used
+func NewWindowActionAverager(window time.Duration, numBuckets int, clock Clock) (ActionAverager, error) {
+	if numBuckets <= 0 {
+		return nil, fmt.Errorf("number of buckets, %d, must be greater than 0", numBuckets)
+	}
+	if window < time.Duration(numBuckets) {
+		return nil, fmt.Errorf("window, %s, must be at least 1ns per bucket for %d buckets", window, numBuckets)
+	}
+	if clock == nil {
+		clock = time.Now
+	}
+
+	return &WindowActionAverage{
+		bucketWidth: int64(window) / int64(numBuckets),
+		numBuckets:  int64(numBuckets),
+		clock:       clock,
+		actionData: &safeWindowDatastore{
+			Data: make(map[string]*windowActionData),
+		},
+	}, nil
+}
+
+func (wav *WindowActionAverage) currentEpoch() int64 {
+	return wav.clock().UnixNano() / wav.bucketWidth
+}
+
+func (wav *WindowActionAverage) bucketIndex(epoch int64) int64 {
+	// NOTE: Go keeps the sign of the dividend so correct the index for times before the unix epoch
+	index := epoch % wav.numBuckets
+	if index < 0 {
+		index += wav.numBuckets
+	}
+	return index
+}
+
+// AddAction takes a json serialized string and adds the action and time to the current bucket of the action
+func (wav *WindowActionAverage) AddAction(input string) error {
+	actStr, timeFlt, err := parseAction(input)
+	if err != nil {
+		return err
+	}
+
+	wav.addSample(actStr, timeFlt)
+	return nil
+}
+
+// AddSample adds the action and time, it accepts the same actions and times as AddAction
+func (wav *WindowActionAverage) AddSample(actStr string, timeFlt float64) error {
+	if err := validateSample(actStr, timeFlt); err != nil {
+		return err
+	}
+
+	wav.addSample(actStr, timeFlt)
+	return nil
+}
+
+func (wav *WindowActionAverage) addSample(actStr string, timeFlt float64) {
+	epoch := wav.currentEpoch()
+
+	wav.actionData.Mux.Lock()
+	defer wav.actionData.Mux.Unlock()
+
+	data, ok := wav.actionData.Data[actStr]
+	if !ok {
+		data = &windowActionData{
+			Buckets: make([]windowBucket, wav.numBuckets),
+		}
+		wav.actionData.Data[actStr] = data
+	}
+
+	bucket := &data.Buckets[wav.bucketIndex(epoch)]
+	if bucket.Epoch != epoch || bucket.CallCount == 0 {
+		*bucket = windowBucket{
+			Epoch:   epoch,
+			MinTime: timeFlt,
+			MaxTime: timeFlt,
+		}
+	}
+	bucket.TotalTime += timeFlt
+	bucket.CallCount++
+	if timeFlt < bucket.MinTime {
+		bucket.MinTime = timeFlt
+	}
+	if timeFlt > bucket.MaxTime {
+		bucket.MaxTime = timeFlt
+	}
+}
+
+// GetStats computes the average, count, sum, min and max time for each action over the trailing window, actions
+// without any times in the window are not included, ordered by action name
+func (wav *WindowActionAverage) GetStats() string {
+	epoch := wav.currentEpoch()
+
+	wav.actionData.Mux.Lock()
+	defer wav.actionData.Mux.Unlock()
+
+	var output []actionOutputJSON
+	for action, data := range wav.actionData.Data {
+		item := &basicOutputJSON{
+			Action: action,
+		}
+		for i := range data.Buckets {
+			bucket := &data.Buckets[i]
+			if bucket.CallCount == 0 || bucket.Epoch <= epoch-wav.numBuckets || bucket.Epoch > epoch {
+				continue
+			}
+			if item.Count == 0 || bucket.MinTime < item.Min {
+				item.Min = bucket.MinTime
+			}
+			if item.Count == 0 || bucket.MaxTime > item.Max {
+				item.Max = bucket.MaxTime
+			}
+			item.Sum += bucket.TotalTime
+			item.Count += bucket.CallCount
+		}
+
+		// NOTE: actions that have left the window are removed so memory is only used by recent actions
+		if item.Count == 0 {
+			delete(wav.actionData.Data, action)
+			continue
+		}
+		item.Average = item.Sum / item.Count
+		output = append(output, item)
+	}
+
+	return marshalSortedByAction(output)
+}
@@ -0,0 +1,542 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: averager.proto
+
+package averagerpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AddActionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Action        string                 `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"`
+	Time          float64                `protobuf:"fixed64,2,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddActionRequest) Reset() {
+	*x = AddActionRequest{}
+	mi := &file_averager_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddActionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddActionRequest) ProtoMessage() {}
+
+func (x *AddActionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_averager_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddActionRequest.ProtoReflect.Descriptor instead.
+func (*AddActionRequest) Descriptor() ([]byte, []int) {
+	return file_averager_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AddActionRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *AddActionRequest) GetTime() float64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+type AddActionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddActionResponse) Reset() {
+	*x = AddActionResponse{}
+	mi := &file_averager_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddActionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddActionResponse) ProtoMessage() {}
+
+func (x *AddActionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_averager_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddActionResponse.ProtoReflect.Descriptor instead.
+func (*AddActionResponse) Descriptor() ([]byte, []int) {
+	return file_averager_proto_rawDescGZIP(), []int{1}
+}
+
+type RejectedAction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int64                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RejectedAction) Reset() {
+	*x = RejectedAction{}
+	mi := &file_averager_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RejectedAction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RejectedAction) ProtoMessage() {}
+
+func (x *RejectedAction) ProtoReflect() protoreflect.Message {
+	mi := &file_averager_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RejectedAction.ProtoReflect.Descriptor instead.
+func (*RejectedAction) Descriptor() ([]byte, []int) {
+	return file_averager_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RejectedAction) GetIndex() int64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *RejectedAction) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type AddActionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accepted      int64                  `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Rejected      []*RejectedAction      `protobuf:"bytes,2,rep,name=rejected,proto3" json:"rejected,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddActionsResponse) Reset() {
+	*x = AddActionsResponse{}
+	mi := &file_averager_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddActionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddActionsResponse) ProtoMessage() {}
+
+func (x *AddActionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_averager_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddActionsResponse.ProtoReflect.Descriptor instead.
+func (*AddActionsResponse) Descriptor() ([]byte, []int) {
+	return file_averager_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AddActionsResponse) GetAccepted() int64 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+func (x *AddActionsResponse) GetRejected() []*RejectedAction {
+	if x != nil {
+		return x.Rejected
+	}
+	return nil
+}
+
+type GetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_averager_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_averager_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_averager_proto_rawDescGZIP(), []int{4}
+}
+
+type ActionStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Action        string                 `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"`
+	Avg           float64                `protobuf:"fixed64,2,opt,name=avg,proto3" json:"avg,omitempty"`
+	Count         float64                `protobuf:"fixed64,3,opt,name=count,proto3" json:"count,omitempty"`
+	Sum           float64                `protobuf:"fixed64,4,opt,name=sum,proto3" json:"sum,omitempty"`
+	Min           float64                `protobuf:"fixed64,5,opt,name=min,proto3" json:"min,omitempty"`
+	Max           float64                `protobuf:"fixed64,6,opt,name=max,proto3" json:"max,omitempty"`
+	Variance      float64                `protobuf:"fixed64,7,opt,name=variance,proto3" json:"variance,omitempty"`
+	Stddev        float64                `protobuf:"fixed64,8,opt,name=stddev,proto3" json:"stddev,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ActionStats) Reset() {
+	*x = ActionStats{}
+	mi := &file_averager_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActionStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActionStats) ProtoMessage() {}
+
+func (x *ActionStats) ProtoReflect() protoreflect.Message {
+	mi := &file_averager_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActionStats.ProtoReflect.Descriptor instead.
+func (*ActionStats) Descriptor() ([]byte, []int) {
+	return file_averager_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ActionStats) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *ActionStats) GetAvg() float64 {
+	if x != nil {
+		return x.Avg
+	}
+	return 0
+}
+
+func (x *ActionStats) GetCount() float64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *ActionStats) GetSum() float64 {
+	if x != nil {
+		return x.Sum
+	}
+	return 0
+}
+
+func (x *ActionStats) GetMin() float64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *ActionStats) GetMax() float64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *ActionStats) GetVariance() float64 {
+	if x != nil {
+		return x.Variance
+	}
+	return 0
+}
+
+func (x *ActionStats) GetStddev() float64 {
+	if x != nil {
+		return x.Stddev
+	}
+	return 0
+}
+
+type GetStatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stats         []*ActionStats         `protobuf:"bytes,1,rep,name=stats,proto3" json:"stats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsResponse) Reset() {
+	*x = GetStatsResponse{}
+	mi := &file_averager_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsResponse) ProtoMessage() {}
+
+func (x *GetStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_averager_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetStatsResponse) Descriptor() ([]byte, []int) {
+	return file_averager_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetStatsResponse) GetStats() []*ActionStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+type WatchStatsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// interval_ms is the least number of milliseconds between stats, the server default is used when it is 0
+	IntervalMs    int64 `protobuf:"varint,1,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchStatsRequest) Reset() {
+	*x = WatchStatsRequest{}
+	mi := &file_averager_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchStatsRequest) ProtoMessage() {}
+
+func (x *WatchStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_averager_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchStatsRequest.ProtoReflect.Descriptor instead.
+func (*WatchStatsRequest) Descriptor() ([]byte, []int) {
+	return file_averager_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchStatsRequest) GetIntervalMs() int64 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+var File_averager_proto protoreflect.FileDescriptor
+
+const file_averager_proto_rawDesc = "" +
+	"\n" +
+	"\x0eaverager.proto\x12\x1crtalleyman.actionaverager.v1\">\n" +
+	"\x10AddActionRequest\x12\x16\n" +
+	"\x06action\x18\x01 \x01(\tR\x06action\x12\x12\n" +
+	"\x04time\x18\x02 \x01(\x01R\x04time\"\x13\n" +
+	"\x11AddActionResponse\"<\n" +
+	"\x0eRejectedAction\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x03R\x05index\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"z\n" +
+	"\x12AddActionsResponse\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\x03R\baccepted\x12H\n" +
+	"\brejected\x18\x02 \x03(\v2,.rtalleyman.actionaverager.v1.RejectedActionR\brejected\"\x11\n" +
+	"\x0fGetStatsRequest\"\xb7\x01\n" +
+	"\vActionStats\x12\x16\n" +
+	"\x06action\x18\x01 \x01(\tR\x06action\x12\x10\n" +
+	"\x03avg\x18\x02 \x01(\x01R\x03avg\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x01R\x05count\x12\x10\n" +
+	"\x03sum\x18\x04 \x01(\x01R\x03sum\x12\x10\n" +
+	"\x03min\x18\x05 \x01(\x01R\x03min\x12\x10\n" +
+	"\x03max\x18\x06 \x01(\x01R\x03max\x12\x1a\n" +
+	"\bvariance\x18\a \x01(\x01R\bvariance\x12\x16\n" +
+	"\x06stddev\x18\b \x01(\x01R\x06stddev\"S\n" +
+	"\x10GetStatsResponse\x12?\n" +
+	"\x05stats\x18\x01 \x03(\v2).rtalleyman.actionaverager.v1.ActionStatsR\x05stats\"4\n" +
+	"\x11WatchStatsRequest\x12\x1f\n" +
+	"\vinterval_ms\x18\x01 \x01(\x03R\n" +
+	"intervalMs2\xcc\x03\n" +
+	"\x0eActionAverager\x12l\n" +
+	"\tAddAction\x12..rtalleyman.actionaverager.v1.AddActionRequest\x1a/.rtalleyman.actionaverager.v1.AddActionResponse\x12p\n" +
+	"\n" +
+	"AddActions\x12..rtalleyman.actionaverager.v1.AddActionRequest\x1a0.rtalleyman.actionaverager.v1.AddActionsResponse(\x01\x12i\n" +
+	"\bGetStats\x12-.rtalleyman.actionaverager.v1.GetStatsRequest\x1a..rtalleyman.actionaverager.v1.GetStatsResponse\x12o\n" +
+	"\n" +
+	"WatchStats\x12/.rtalleyman.actionaverager.v1.WatchStatsRequest\x1a..rtalleyman.actionaverager.v1.GetStatsResponse0\x01B8Z6github.com/action-averager/pkg/grpcaverager/averagerpbb\x06proto3"
+
+var (
+	file_averager_proto_rawDescOnce sync.Once
+	file_averager_proto_rawDescData []byte
+)
+
+func file_averager_proto_rawDescGZIP() []byte {
+	file_averager_proto_rawDescOnce.Do(func() {
+		file_averager_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_averager_proto_rawDesc), len(file_averager_proto_rawDesc)))
+	})
+	return file_averager_proto_rawDescData
+}
+
+var file_averager_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_averager_proto_goTypes = []any{
+	(*AddActionRequest)(nil),   // 0: rtalleyman.actionaverager.v1.AddActionRequest
+	(*AddActionResponse)(nil),  // 1: rtalleyman.actionaverager.v1.AddActionResponse
+	(*RejectedAction)(nil),     // 2: rtalleyman.actionaverager.v1.RejectedAction
+	(*AddActionsResponse)(nil), // 3: rtalleyman.actionaverager.v1.AddActionsResponse
+	(*GetStatsRequest)(nil),    // 4: rtalleyman.actionaverager.v1.GetStatsRequest
+	(*ActionStats)(nil),        // 5: rtalleyman.actionaverager.v1.ActionStats
+	(*GetStatsResponse)(nil),   // 6: rtalleyman.actionaverager.v1.GetStatsResponse
+	(*WatchStatsRequest)(nil),  // 7: rtalleyman.actionaverager.v1.WatchStatsRequest
+}
+var file_averager_proto_depIdxs = []int32{
+	2, // 0: rtalleyman.actionaverager.v1.AddActionsResponse.rejected:type_name -> rtalleyman.actionaverager.v1.RejectedAction
+	5, // 1: rtalleyman.actionaverager.v1.GetStatsResponse.stats:type_name -> rtalleyman.actionaverager.v1.ActionStats
+	0, // 2: rtalleyman.actionaverager.v1.ActionAverager.AddAction:input_type -> rtalleyman.actionaverager.v1.AddActionRequest
+	0, // 3: rtalleyman.actionaverager.v1.ActionAverager.AddActions:input_type -> rtalleyman.actionaverager.v1.AddActionRequest
+	4, // 4: rtalleyman.actionaverager.v1.ActionAverager.GetStats:input_type -> rtalleyman.actionaverager.v1.GetStatsRequest
+	7, // 5: rtalleyman.actionaverager.v1.ActionAverager.WatchStats:input_type -> rtalleyman.actionaverager.v1.WatchStatsRequest
+	1, // 6: rtalleyman.actionaverager.v1.ActionAverager.AddAction:output_type -> rtalleyman.actionaverager.v1.AddActionResponse
+	3, // 7: rtalleyman.actionaverager.v1.ActionAverager.AddActions:output_type -> rtalleyman.actionaverager.v1.AddActionsResponse
+	6, // 8: rtalleyman.actionaverager.v1.ActionAverager.GetStats:output_type -> rtalleyman.actionaverager.v1.GetStatsResponse
+	6, // 9: rtalleyman.actionaverager.v1.ActionAverager.WatchStats:output_type -> rtalleyman.actionaverager.v1.GetStatsResponse
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_averager_proto_init() }
+func file_averager_proto_init() {
+	if File_averager_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_averager_proto_rawDesc), len(file_averager_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_averager_proto_goTypes,
+		DependencyIndexes: file_averager_proto_depIdxs,
+		MessageInfos:      file_averager_proto_msgTypes,
+	}.Build()
+	File_averager_proto = out.File
+	file_averager_proto_goTypes = nil
+	file_averager_proto_depIdxs = nil
+}
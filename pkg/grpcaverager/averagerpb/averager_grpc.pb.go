@@ -0,0 +1,246 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: averager.proto
+
+package averagerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ActionAverager_AddAction_FullMethodName  = "/rtalleyman.actionaverager.v1.ActionAverager/AddAction"
+	ActionAverager_AddActions_FullMethodName = "/rtalleyman.actionaverager.v1.ActionAverager/AddActions"
+	ActionAverager_GetStats_FullMethodName   = "/rtalleyman.actionaverager.v1.ActionAverager/GetStats"
+	ActionAverager_WatchStats_FullMethodName = "/rtalleyman.actionaverager.v1.ActionAverager/WatchStats"
+)
+
+// ActionAveragerClient is the client API for ActionAverager service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ActionAverager averages times for actions
+type ActionAveragerClient interface {
+	// AddAction adds a single action and time
+	AddAction(ctx context.Context, in *AddActionRequest, opts ...grpc.CallOption) (*AddActionResponse, error)
+	// AddActions adds every action and time streamed by the client, rejected actions are reported by their index
+	// in the stream instead of ending the stream
+	AddActions(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[AddActionRequest, AddActionsResponse], error)
+	// GetStats returns the stats for every action
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
+	// WatchStats streams the stats for every action right away and then whenever they change
+	WatchStats(ctx context.Context, in *WatchStatsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetStatsResponse], error)
+}
+
+type actionAveragerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewActionAveragerClient(cc grpc.ClientConnInterface) ActionAveragerClient {
+	return &actionAveragerClient{cc}
+}
+
+func (c *actionAveragerClient) AddAction(ctx context.Context, in *AddActionRequest, opts ...grpc.CallOption) (*AddActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddActionResponse)
+	err := c.cc.Invoke(ctx, ActionAverager_AddAction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *actionAveragerClient) AddActions(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[AddActionRequest, AddActionsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ActionAverager_ServiceDesc.Streams[0], ActionAverager_AddActions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AddActionRequest, AddActionsResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ActionAverager_AddActionsClient = grpc.ClientStreamingClient[AddActionRequest, AddActionsResponse]
+
+func (c *actionAveragerClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatsResponse)
+	err := c.cc.Invoke(ctx, ActionAverager_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *actionAveragerClient) WatchStats(ctx context.Context, in *WatchStatsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetStatsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ActionAverager_ServiceDesc.Streams[1], ActionAverager_WatchStats_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchStatsRequest, GetStatsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ActionAverager_WatchStatsClient = grpc.ServerStreamingClient[GetStatsResponse]
+
+// ActionAveragerServer is the server API for ActionAverager service.
+// All implementations must embed UnimplementedActionAveragerServer
+// for forward compatibility.
+//
+// ActionAverager averages times for actions
+type ActionAveragerServer interface {
+	// AddAction adds a single action and time
+	AddAction(context.Context, *AddActionRequest) (*AddActionResponse, error)
+	// AddActions adds every action and time streamed by the client, rejected actions are reported by their index
+	// in the stream instead of ending the stream
+	AddActions(grpc.ClientStreamingServer[AddActionRequest, AddActionsResponse]) error
+	// GetStats returns the stats for every action
+	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
+	// WatchStats streams the stats for every action right away and then whenever they change
+	WatchStats(*WatchStatsRequest, grpc.ServerStreamingServer[GetStatsResponse]) error
+	mustEmbedUnimplementedActionAveragerServer()
+}
+
+// UnimplementedActionAveragerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedActionAveragerServer struct{}
+
+func (UnimplementedActionAveragerServer) AddAction(context.Context, *AddActionRequest) (*AddActionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method AddAction not implemented")
+}
+func (UnimplementedActionAveragerServer) AddActions(grpc.ClientStreamingServer[AddActionRequest, AddActionsResponse]) error {
+	return status.Error(codes.Unimplemented, "method AddActions not implemented")
+}
+func (UnimplementedActionAveragerServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedActionAveragerServer) WatchStats(*WatchStatsRequest, grpc.ServerStreamingServer[GetStatsResponse]) error {
+	return status.Error(codes.Unimplemented, "method WatchStats not implemented")
+}
+func (UnimplementedActionAveragerServer) mustEmbedUnimplementedActionAveragerServer() {}
+func (UnimplementedActionAveragerServer) testEmbeddedByValue()                        {}
+
+// UnsafeActionAveragerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ActionAveragerServer will
+// result in compilation errors.
+type UnsafeActionAveragerServer interface {
+	mustEmbedUnimplementedActionAveragerServer()
+}
+
+func RegisterActionAveragerServer(s grpc.ServiceRegistrar, srv ActionAveragerServer) {
+	// If the following call panics, it indicates UnimplementedActionAveragerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ActionAverager_ServiceDesc, srv)
+}
+
+func _ActionAverager_AddAction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ActionAveragerServer).AddAction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ActionAverager_AddAction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ActionAveragerServer).AddAction(ctx, req.(*AddActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ActionAverager_AddActions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ActionAveragerServer).AddActions(&grpc.GenericServerStream[AddActionRequest, AddActionsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ActionAverager_AddActionsServer = grpc.ClientStreamingServer[AddActionRequest, AddActionsResponse]
+
+func _ActionAverager_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ActionAveragerServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ActionAverager_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ActionAveragerServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ActionAverager_WatchStats_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchStatsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ActionAveragerServer).WatchStats(m, &grpc.GenericServerStream[WatchStatsRequest, GetStatsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ActionAverager_WatchStatsServer = grpc.ServerStreamingServer[GetStatsResponse]
+
+// ActionAverager_ServiceDesc is the grpc.ServiceDesc for ActionAverager service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ActionAverager_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rtalleyman.actionaverager.v1.ActionAverager",
+	HandlerType: (*ActionAveragerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddAction",
+			Handler:    _ActionAverager_AddAction_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _ActionAverager_GetStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AddActions",
+			Handler:       _ActionAverager_AddActions_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchStats",
+			Handler:       _ActionAverager_WatchStats_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "averager.proto",
+}
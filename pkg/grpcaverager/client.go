@@ -0,0 +1,78 @@
+package grpcaverager
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/action-averager/pkg/actionaverager"
+	"github.com/action-averager/pkg/grpcaverager/averagerpb"
+)
+
+// Client is a typed client of the ActionAverager gRPC service
+type Client struct {
+	client averagerpb.ActionAveragerClient
+}
+
+// NewClient creates a new Client that uses conn
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{
+		client: averagerpb.NewActionAveragerClient(conn),
+	}
+}
+
+// AddSample adds a single action and time
+func (c *Client) AddSample(ctx context.Context, actStr string, timeFlt float64) error {
+	_, err := c.client.AddAction(ctx, &averagerpb.AddActionRequest{Action: actStr, Time: timeFlt})
+	return err
+}
+
+// AddSamples streams every action and time to the server, the response has the number of accepted actions and the
+// index of every rejected action
+func (c *Client) AddSamples(ctx context.Context, samples []*averagerpb.AddActionRequest) (*averagerpb.AddActionsResponse, error) {
+	stream, err := c.client.AddActions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range samples {
+		if err := stream.Send(samples[i]); err != nil {
+			// NOTE: io.EOF means the server ended the stream, the real error is returned by CloseAndRecv
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// GetStats returns the stats for every action
+func (c *Client) GetStats(ctx context.Context) ([]actionaverager.ActionStats, error) {
+	resp, err := c.client.GetStats(ctx, &averagerpb.GetStatsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return fromStatsResponse(resp), nil
+}
+
+// WatchStats calls handler with the stats for every action right away and then whenever they change, at most
+// once every interval. It blocks until ctx is done or the stream ends with an error.
+func (c *Client) WatchStats(ctx context.Context, interval time.Duration, handler func([]actionaverager.ActionStats)) error {
+	stream, err := c.client.WatchStats(ctx, &averagerpb.WatchStatsRequest{IntervalMs: interval.Milliseconds()})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			// NOTE: return the error of a done context instead of the status error it causes
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		handler(fromStatsResponse(resp))
+	}
+}
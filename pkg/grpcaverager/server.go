@@ -0,0 +1,164 @@
+package grpcaverager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/action-averager/pkg/actionaverager"
+	"github.com/action-averager/pkg/grpcaverager/averagerpb"
+)
+
+// StatsLister is an ActionAverager that can return its stats without json
+type StatsLister interface {
+	actionaverager.ActionAverager
+	GetStatsList() []actionaverager.ActionStats
+}
+
+// Server implements the ActionAverager gRPC service around an ActionAverager
+type Server struct {
+	averagerpb.UnimplementedActionAveragerServer
+	averager actionaverager.ActionAverager
+}
+
+// NewServer creates a new Server around averager, register it with averagerpb.RegisterActionAveragerServer
+func NewServer(averager actionaverager.ActionAverager) *Server {
+	return &Server{
+		averager: averager,
+	}
+}
+
+// AddAction adds a single action and time, rejected actions return an InvalidArgument status, actions dropped by a
+// full queue a ResourceExhausted status and any other error an Internal status
+func (s *Server) AddAction(_ context.Context, req *averagerpb.AddActionRequest) (*averagerpb.AddActionResponse, error) {
+	if err := actionaverager.AddSample(s.averager, req.GetAction(), req.GetTime()); err != nil {
+		return nil, status.Error(addErrorCode(err), err.Error())
+	}
+	return &averagerpb.AddActionResponse{}, nil
+}
+
+// addErrorCode returns the status code for an error from adding an action, only rejected input is an invalid
+// argument
+func addErrorCode(err error) codes.Code {
+	var validationErr *actionaverager.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return codes.InvalidArgument
+	case errors.Is(err, actionaverager.ErrQueueFull):
+		return codes.ResourceExhausted
+	default:
+		return codes.Internal
+	}
+}
+
+// AddActions adds every action and time streamed by the client, rejected actions are reported by their index in
+// the stream once the client closes the stream. Any other error ends the stream with the same status as AddAction,
+// since it is not about the input and would fail the rest of the stream as well.
+func (s *Server) AddActions(stream averagerpb.ActionAverager_AddActionsServer) error {
+	resp := &averagerpb.AddActionsResponse{}
+	for index := int64(0); ; index++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := actionaverager.AddSample(s.averager, req.GetAction(), req.GetTime()); err != nil {
+			if code := addErrorCode(err); code != codes.InvalidArgument {
+				return status.Errorf(code, "index %d: %v", index, err)
+			}
+			resp.Rejected = append(resp.Rejected, &averagerpb.RejectedAction{Index: index, Error: err.Error()})
+			continue
+		}
+		resp.Accepted++
+	}
+}
+
+// GetStats returns the stats for every action
+func (s *Server) GetStats(context.Context, *averagerpb.GetStatsRequest) (*averagerpb.GetStatsResponse, error) {
+	if statsLister, ok := s.averager.(StatsLister); ok {
+		return toStatsResponse(statsLister.GetStatsList()), nil
+	}
+	return decodeStats(s.averager.GetStats())
+}
+
+// WatchStats streams the stats for every action right away and then whenever they change, until the client cancels
+func (s *Server) WatchStats(req *averagerpb.WatchStatsRequest, stream averagerpb.ActionAverager_WatchStatsServer) error {
+	subscriber, ok := s.averager.(actionaverager.StatsSubscriber)
+	if !ok {
+		return status.Error(codes.Unimplemented, "averager does not support subscribing to stats")
+	}
+
+	// NOTE: Subscribe uses its default interval for an interval that is not greater than 0
+	statsCh, unsubscribe := subscriber.Subscribe(time.Duration(req.GetIntervalMs()) * time.Millisecond)
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case stats, ok := <-statsCh:
+			if !ok {
+				return nil
+			}
+			resp, err := decodeStats(stats)
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// decodeStats converts the json output of GetStats, fields that an averager does not output are left as 0
+func decodeStats(statsJSON string) (*averagerpb.GetStatsResponse, error) {
+	var stats []actionaverager.ActionStats
+	if err := json.Unmarshal([]byte(statsJSON), &stats); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toStatsResponse(stats), nil
+}
+
+func toStatsResponse(stats []actionaverager.ActionStats) *averagerpb.GetStatsResponse {
+	resp := &averagerpb.GetStatsResponse{
+		Stats: make([]*averagerpb.ActionStats, len(stats)),
+	}
+	for i := range stats {
+		resp.Stats[i] = &averagerpb.ActionStats{
+			Action:   stats[i].Action,
+			Avg:      stats[i].Average,
+			Count:    stats[i].Count,
+			Sum:      stats[i].Sum,
+			Min:      stats[i].Min,
+			Max:      stats[i].Max,
+			Variance: stats[i].Variance,
+			Stddev:   stats[i].StdDev,
+		}
+	}
+	return resp
+}
+
+func fromStatsResponse(resp *averagerpb.GetStatsResponse) []actionaverager.ActionStats {
+	stats := make([]actionaverager.ActionStats, len(resp.GetStats()))
+	for i, item := range resp.GetStats() {
+		stats[i] = actionaverager.ActionStats{
+			Action:   item.GetAction(),
+			Average:  item.GetAvg(),
+			Count:    item.GetCount(),
+			Sum:      item.GetSum(),
+			Min:      item.GetMin(),
+			Max:      item.GetMax(),
+			Variance: item.GetVariance(),
+			StdDev:   item.GetStddev(),
+		}
+	}
+	return stats
+}
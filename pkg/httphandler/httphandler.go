@@ -0,0 +1,297 @@
+package httphandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const (
+	// DefaultMaxBodySize is the largest request body accepted when no max body size is given
+	DefaultMaxBodySize = 1024 * 1024
+
+	// ActionsPath accepts POST requests with a single json action or a json array of actions
+	ActionsPath = "/actions"
+	// StatsPath accepts GET requests for the stats of every action, or of a single action at StatsPath/{action}
+	StatsPath = "/stats"
+	// EventsPath accepts GET requests for a server-sent events stream of the stats of every action, the stats are
+	// sent when they change at most once every interval, which can be set with the interval query parameter
+	EventsPath = "/events"
+
+	// DefaultEventInterval is the interval between stats events when the interval query parameter is not given
+	DefaultEventInterval = time.Second
+	// MinEventInterval is the smallest interval between stats events a client can ask for
+	MinEventInterval = 100 * time.Millisecond
+
+	contentTypeKey         = "Content-Type"
+	contentTypeJSON        = "application/json"
+	contentTypeEventStream = "text/event-stream"
+	intervalParam          = "interval"
+	batchStart             = '['
+)
+
+// BatchAverager is an ActionAverager that also accepts a json array of actions
+type BatchAverager interface {
+	actionaverager.ActionAverager
+	AddActions(string) error
+}
+
+// StatsGetter is an ActionAverager that can look up the stats of a single action
+type StatsGetter interface {
+	actionaverager.ActionAverager
+	GetActionStats(string) (actionaverager.ActionStats, bool)
+}
+
+type errorJSON struct {
+	Error    string              `json:"error"`
+	Rejected []*rejectedItemJSON `json:"rejected,omitempty"`
+}
+
+type rejectedItemJSON struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// Handler is an http.Handler that exposes an ActionAverager
+type Handler struct {
+	averager    actionaverager.ActionAverager
+	maxBodySize int64
+}
+
+// NewHandler creates a new Handler around averager, request bodies larger than maxBodySize are rejected, if
+// maxBodySize is not greater than 0 DefaultMaxBodySize is used
+func NewHandler(averager actionaverager.ActionAverager, maxBodySize int64) *Handler {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+
+	return &Handler{
+		averager:    averager,
+		maxBodySize: maxBodySize,
+	}
+}
+
+// ServeHTTP routes requests to the actions and stats endpoints. Rejected actions respond with 400 and the error
+// as json, a batch with rejected actions still adds the rest of the batch and also responds with the index of
+// every rejected action. Actions dropped by a full queue respond with 503 and any other error with 500.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == ActionsPath:
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		h.handleAddActions(w, r)
+	case r.URL.Path == StatsPath:
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, http.StatusOK, []byte(h.averager.GetStats()))
+	case strings.HasPrefix(r.URL.Path, StatsPath+"/"):
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		h.handleGetActionStats(w, strings.TrimPrefix(r.URL.Path, StatsPath+"/"))
+	case r.URL.Path == EventsPath:
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		h.handleEvents(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) handleAddActions(w http.ResponseWriter, r *http.Request) {
+	// NOTE: read one byte more than the limit so a body of exactly the limit is allowed, but a larger one is not
+	body, err := io.ReadAll(io.LimitReader(r.Body, h.maxBodySize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if int64(len(body)) > h.maxBodySize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body is larger than %d bytes, rejecting", h.maxBodySize))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == batchStart {
+		err = h.addBatch(string(body))
+	} else {
+		err = h.averager.AddAction(string(body))
+	}
+	if err != nil {
+		writeError(w, addErrorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addErrorStatus returns the status for an error from adding actions, only rejected input is a bad request. A full
+// queue is temporary so it is unavailable, anything else like a closed averager or a failed write is an internal
+// error.
+func addErrorStatus(err error) int {
+	var validationErr *actionaverager.ValidationError
+	var batchErr *actionaverager.BatchError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErr), errors.As(err, &batchErr), errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return http.StatusBadRequest
+	case errors.Is(err, actionaverager.ErrQueueFull):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// addBatch adds a json array of actions, averagers that do not accept batches have each action added separately.
+// Rejected actions are reported in a BatchError, but any other error is returned right away without adding the
+// rest of the batch, since it is not about the input and would fail the rest of the batch as well.
+func (h *Handler) addBatch(input string) error {
+	if batchAverager, ok := h.averager.(BatchAverager); ok {
+		return batchAverager.AddActions(input)
+	}
+
+	var rawActions []json.RawMessage
+	if err := json.Unmarshal([]byte(input), &rawActions); err != nil {
+		return err
+	}
+	batchErr := &actionaverager.BatchError{}
+	for i := range rawActions {
+		err := h.averager.AddAction(string(rawActions[i]))
+		if err == nil {
+			continue
+		}
+		var validationErr *actionaverager.ValidationError
+		if !errors.As(err, &validationErr) {
+			return err
+		}
+		batchErr.Errors = append(batchErr.Errors, &actionaverager.BatchItemError{Index: i, Err: err})
+	}
+	if len(batchErr.Errors) == 0 {
+		return nil
+	}
+	return batchErr
+}
+
+func (h *Handler) handleGetActionStats(w http.ResponseWriter, actStr string) {
+	if statsGetter, ok := h.averager.(StatsGetter); ok {
+		stats, ok := statsGetter.GetActionStats(actStr)
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("action %s not found", actStr))
+			return
+		}
+		// WARNING: suppressing error, since ActionStats is always able to be marshaled
+		statsJSON, _ := json.Marshal(stats)
+		writeJSON(w, http.StatusOK, statsJSON)
+		return
+	}
+
+	// NOTE: averagers that can not look up a single action are handled by searching the output of GetStats
+	var allStats []json.RawMessage
+	if err := json.Unmarshal([]byte(h.averager.GetStats()), &allStats); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for i := range allStats {
+		var item struct {
+			Action string `json:"action"`
+		}
+		if err := json.Unmarshal(allStats[i], &item); err == nil && item.Action == actStr {
+			writeJSON(w, http.StatusOK, allStats[i])
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("action %s not found", actStr))
+}
+
+// handleEvents streams the stats as server-sent events until the client disconnects
+func (h *Handler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	subscriber, ok := h.averager.(actionaverager.StatsSubscriber)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("averager does not support subscribing to stats"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("response does not support streaming"))
+		return
+	}
+
+	interval := DefaultEventInterval
+	if intervalStr := r.URL.Query().Get(intervalParam); intervalStr != "" {
+		var err error
+		interval, err = time.ParseDuration(intervalStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if interval < MinEventInterval {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("interval %s is less than the minimum interval %s", interval, MinEventInterval))
+			return
+		}
+	}
+
+	statsCh, unsubscribe := subscriber.Subscribe(interval)
+	defer unsubscribe()
+
+	w.Header().Set(contentTypeKey, contentTypeEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case stats, ok := <-statsCh:
+			if !ok {
+				return
+			}
+			// NOTE: GetStats output is a single line of json, so it is always a single data field
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", stats); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed, use %s", r.Method, method))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set(contentTypeKey, contentTypeJSON)
+	w.WriteHeader(status)
+	// NOTE: nothing can be done about a failed write, since the status has already been sent
+	_, _ = w.Write(body)
+}
+
+// writeError writes err as json, a *actionaverager.BatchError also has the index of every rejected action
+func writeError(w http.ResponseWriter, status int, err error) {
+	output := &errorJSON{
+		Error: err.Error(),
+	}
+	var batchErr *actionaverager.BatchError
+	if errors.As(err, &batchErr) {
+		for _, itemErr := range batchErr.Errors {
+			output.Rejected = append(output.Rejected, &rejectedItemJSON{Index: itemErr.Index, Error: itemErr.Err.Error()})
+		}
+	}
+
+	// WARNING: suppressing error, since errorJSON is always able to be marshaled
+	errJSON, _ := json.Marshal(output)
+	writeJSON(w, status, errJSON)
+}
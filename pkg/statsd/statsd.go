@@ -0,0 +1,177 @@
+package statsd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const (
+	// maxPacketSize is the largest UDP packet that can be received
+	maxPacketSize = 64 * 1024
+
+	timerType        = "ms"
+	histogramType    = "h"
+	nameSep          = ":"
+	fieldSep         = "|"
+	lineSep          = "\n"
+	sampleRatePrefix = "@"
+	tagsPrefix       = "#"
+)
+
+// Metric is a single StatsD timing
+type Metric struct {
+	Action     string
+	Time       float64
+	SampleRate float64
+}
+
+// ParseLine parses a single StatsD timing line like "jump:111|ms", "jump:111|ms|@0.5" or "jump:111:98|ms", into
+// a Metric for each value. Timers (ms) and histograms (h) are accepted, other metric types are rejected since they
+// are not times. DogStatsD tags, like "|#region:us", are accepted and ignored.
+func ParseLine(line string) ([]Metric, error) {
+	fields := strings.Split(line, fieldSep)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("line %q is missing a metric type, rejecting", line)
+	}
+
+	nameValues := strings.Split(fields[0], nameSep)
+	if len(nameValues) < 2 || nameValues[0] == "" {
+		return nil, fmt.Errorf("line %q is missing a name or value, rejecting", line)
+	}
+
+	if fields[1] != timerType && fields[1] != histogramType {
+		return nil, fmt.Errorf("metric type %q in line %q is not a timer, rejecting", fields[1], line)
+	}
+
+	sampleRate := float64(1)
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, sampleRatePrefix):
+			rate, err := strconv.ParseFloat(strings.TrimPrefix(field, sampleRatePrefix), 64)
+			if err != nil || !(rate > 0 && rate <= 1) {
+				return nil, fmt.Errorf("sample rate %q in line %q is not greater than 0 and at most 1, rejecting", field, line)
+			}
+			sampleRate = rate
+		case strings.HasPrefix(field, tagsPrefix):
+		default:
+			return nil, fmt.Errorf("unexpected field %q in line %q, rejecting", field, line)
+		}
+	}
+
+	metrics := make([]Metric, 0, len(nameValues)-1)
+	for _, value := range nameValues[1:] {
+		timeFlt, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value %q in line %q is not a number, rejecting", value, line)
+		}
+		metrics = append(metrics, Metric{
+			Action:     nameValues[0],
+			Time:       timeFlt,
+			SampleRate: sampleRate,
+		})
+	}
+	return metrics, nil
+}
+
+// Server feeds StatsD timings received over UDP or TCP into an ActionAverager
+type Server struct {
+	averager   actionaverager.ActionAverager
+	errHandler func(error)
+}
+
+// NewServer creates a new Server that adds timings to averager, errHandler is called with every rejected line and
+// can be nil to ignore them
+func NewServer(averager actionaverager.ActionAverager, errHandler func(error)) *Server {
+	if errHandler == nil {
+		errHandler = func(error) {}
+	}
+
+	return &Server{
+		averager:   averager,
+		errHandler: errHandler,
+	}
+}
+
+// HandlePacket adds every timing in a packet of newline separated StatsD lines, blank lines are skipped
+func (s *Server) HandlePacket(packet string) {
+	for _, line := range strings.Split(packet, lineSep) {
+		s.handleLine(line)
+	}
+}
+
+// NOTE: a value sampled at a rate below 1 stands for 1/rate values, so it is added with a weight of 1/rate to keep
+// the count and sum unbiased. Averagers that do not accept weighted samples have it added once, which still keeps
+// the average unbiased, but the count and sum are only of the values actually received.
+func (s *Server) handleLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	metrics, err := ParseLine(line)
+	if err != nil {
+		s.errHandler(err)
+		return
+	}
+	weightedAverager, weighted := s.averager.(actionaverager.WeightedAverager)
+	for i := range metrics {
+		if weighted && metrics[i].SampleRate < 1 {
+			err = weightedAverager.AddWeightedSample(metrics[i].Action, metrics[i].Time, 1/metrics[i].SampleRate)
+		} else {
+			err = actionaverager.AddSample(s.averager, metrics[i].Action, metrics[i].Time)
+		}
+		if err != nil {
+			s.errHandler(err)
+		}
+	}
+}
+
+// ServeUDP handles every packet received on conn until conn is closed, closing conn returns nil
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if n > 0 {
+			s.HandlePacket(string(buf[:n]))
+		}
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// ServeTCP handles newline separated lines on every connection accepted on listener until listener is closed,
+// closing listener returns nil. Connections that are already accepted are served until the client closes them.
+func (s *Server) ServeTCP(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		s.handleLine(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		s.errHandler(err)
+	}
+}
@@ -1,6 +1,8 @@
 package actionaverager_test
 
 import (
+	"encoding/json"
+	"errors"
 	"strings"
 	"time"
 
@@ -13,10 +15,8 @@ import (
 const (
 	emptyStats = "[]"
 
-	delay     = true
-	verifyAll = true
+	delay = true
 
-	replace1      = 1
 	delayDuration = 10
 )
 
@@ -31,21 +31,25 @@ func addMultipleActions(averager actionaverager.ActionAverager, actions []string
 	}
 }
 
-func verifyMultipleDifferentStats(stats string, expSubstrings []string, fullVerify bool) {
-	expNumCommas := len(expSubstrings) - 1
-	for i := range expSubstrings {
-		//NOTE: use ContainSubstring, since order of actions is go map order so explicit string equals fails intermittently
-		Expect(stats).To(ContainSubstring(expSubstrings[i]))
-		stats = strings.Replace(stats, expSubstrings[i], "", replace1)
-	}
-	// NOTE: this make sure the string only contains what is in the expected substrings and that it is properly formatted
-	// meaning there should be 1 less comma than fields and only 1 [ and ]. This should not be run in some concurrent cases
-	// where the exact output can not be guaranteed at any time i.e. concurrent calls of both AddAction and GetStats.
-	if fullVerify {
-		stats = strings.Replace(stats, ",", "", expNumCommas)
-		stats = strings.Replace(stats, "[", "", replace1)
-		stats = strings.Replace(stats, "]", "", replace1)
-		Expect(stats).To(BeEmpty())
+// verifyValidationError checks that err is a ValidationError for reason and field that errors.Is and errors.As
+// can match
+func verifyValidationError(err error, reason error, field string) {
+	Expect(errors.Is(err, reason)).To(BeTrue(), "error %v is not %v", err, reason)
+	var validationErr *actionaverager.ValidationError
+	Expect(errors.As(err, &validationErr)).To(BeTrue())
+	Expect(validationErr.Field).To(Equal(field))
+}
+
+func verifyStats(stats string, expStats []string) {
+	// NOTE: stats are ordered by action name so expStats must be ordered by action name as well
+	Expect(stats).To(Equal("[" + strings.Join(expStats, ",") + "]"))
+}
+
+func verifyMinimumStats(stats string, minExpStats []string) {
+	// NOTE: this should only be used in concurrent cases where the exact output can not be guaranteed at any time
+	// i.e. concurrent calls of both AddAction and GetStats, so only the stats that must be there are verified
+	for i := range minExpStats {
+		Expect(stats).To(ContainSubstring(minExpStats[i]))
 	}
 }
 
@@ -62,7 +66,7 @@ var _ = Describe("action-averager tests", func() {
 				err := averager.AddAction(`{"action":"run","time":20}`)
 				Expect(err).NotTo(HaveOccurred())
 				stats := averager.GetStats()
-				Expect(stats).To(Equal(`[{"action":"run","avg":20}]`))
+				Expect(stats).To(Equal(`[{"action":"run","avg":20,"count":1,"sum":20,"min":20,"max":20,"variance":0,"stddev":0}]`))
 			})
 
 			It("should not average multiple inputs to different actions", func() {
@@ -73,10 +77,10 @@ var _ = Describe("action-averager tests", func() {
 				addMultipleActions(averager, actions, !delay)
 				stats := averager.GetStats()
 				expStats := []string{
-					`{"action":"run","avg":55}`,
-					`{"action":"skip","avg":145}`,
+					`{"action":"run","avg":55,"count":1,"sum":55,"min":55,"max":55,"variance":0,"stddev":0}`,
+					`{"action":"skip","avg":145,"count":1,"sum":145,"min":145,"max":145,"variance":0,"stddev":0}`,
 				}
-				verifyMultipleDifferentStats(stats, expStats, verifyAll)
+				verifyStats(stats, expStats)
 			})
 
 			It("should average multiple inputs of the same action", func() {
@@ -86,7 +90,7 @@ var _ = Describe("action-averager tests", func() {
 				}
 				addMultipleActions(averager, actions, !delay)
 				stats := averager.GetStats()
-				Expect(stats).To(Equal(`[{"action":"hop","avg":100.435}]`))
+				Expect(stats).To(Equal(`[{"action":"hop","avg":100.435,"count":2,"sum":200.87,"min":55.5,"max":145.37,"variance":2019.1542250000002,"stddev":44.935}]`))
 			})
 
 			It("should average multiple inputs of different actions", func() {
@@ -102,11 +106,11 @@ var _ = Describe("action-averager tests", func() {
 				addMultipleActions(averager, actions, !delay)
 				stats := averager.GetStats()
 				expStats := []string{
-					`{"action":"hop","avg":59.025}`,
-					`{"action":"skip","avg":145.38933333333333}`,
-					`{"action":"jump","avg":32.785}`,
+					`{"action":"hop","avg":59.025,"count":2,"sum":118.05,"min":55.75,"max":62.3,"variance":10.72562499999999,"stddev":3.2749999999999986}`,
+					`{"action":"jump","avg":32.785,"count":2,"sum":65.57,"min":30,"max":35.57,"variance":7.756224999999991,"stddev":2.7849999999999984}`,
+					`{"action":"skip","avg":145.38933333333333,"count":3,"sum":436.168,"min":125.545,"max":155.5,"variance":196.92247088888874,"stddev":14.0329067156056}`,
 				}
-				verifyMultipleDifferentStats(stats, expStats, verifyAll)
+				verifyStats(stats, expStats)
 			})
 
 			It("should handle a mix of single and multiple inputs to different actions", func() {
@@ -120,11 +124,11 @@ var _ = Describe("action-averager tests", func() {
 				addMultipleActions(averager, actions, !delay)
 				stats := averager.GetStats()
 				expStats := []string{
-					`{"action":"walk","avg":225}`,
-					`{"action":"run","avg":125}`,
-					`{"action":"crawl","avg":300}`,
+					`{"action":"crawl","avg":300,"count":1,"sum":300,"min":300,"max":300,"variance":0,"stddev":0}`,
+					`{"action":"run","avg":125,"count":2,"sum":250,"min":100,"max":150,"variance":625,"stddev":25}`,
+					`{"action":"walk","avg":225,"count":2,"sum":450,"min":200,"max":250,"variance":625,"stddev":25}`,
 				}
-				verifyMultipleDifferentStats(stats, expStats, verifyAll)
+				verifyStats(stats, expStats)
 			})
 
 			It("should keep averaging after GetStats is called", func() {
@@ -135,31 +139,54 @@ var _ = Describe("action-averager tests", func() {
 				addMultipleActions(averager, actions, !delay)
 				stats := averager.GetStats()
 				expStats := []string{
-					`{"action":"run","avg":75}`,
-					`{"action":"walk","avg":225}`,
+					`{"action":"run","avg":75,"count":1,"sum":75,"min":75,"max":75,"variance":0,"stddev":0}`,
+					`{"action":"walk","avg":225,"count":1,"sum":225,"min":225,"max":225,"variance":0,"stddev":0}`,
 				}
-				verifyMultipleDifferentStats(stats, expStats, verifyAll)
+				verifyStats(stats, expStats)
 
 				err := averager.AddAction(`{"action":"run","time":80}`)
 				Expect(err).NotTo(HaveOccurred())
 				stats = averager.GetStats()
 				expStats = []string{
-					`{"action":"run","avg":77.5}`,
+					`{"action":"run","avg":77.5,"count":2,"sum":155,"min":75,"max":80,"variance":6.25,"stddev":2.5}`,
 					expStats[1],
 				}
-				verifyMultipleDifferentStats(stats, expStats, verifyAll)
+				verifyStats(stats, expStats)
 			})
 
 			It("should handle an action with a time of 0", func() {
 				err := averager.AddAction(`{"action":"bike","time":0}`)
 				Expect(err).NotTo(HaveOccurred())
 				stats := averager.GetStats()
-				Expect(stats).To(Equal(`[{"action":"bike","avg":0}]`))
+				Expect(stats).To(Equal(`[{"action":"bike","avg":0,"count":1,"sum":0,"min":0,"max":0,"variance":0,"stddev":0}]`))
 
 				err = averager.AddAction(`{"action":"bike","time":50}`)
 				Expect(err).NotTo(HaveOccurred())
 				stats = averager.GetStats()
-				Expect(stats).To(Equal(`[{"action":"bike","avg":25}]`))
+				Expect(stats).To(Equal(`[{"action":"bike","avg":25,"count":2,"sum":50,"min":0,"max":50,"variance":625,"stddev":25}]`))
+			})
+
+			It("should distinguish consistent times from jittery times with the same average", func() {
+				actions := []string{
+					`{"action":"jump","time":100}`,
+					`{"action":"jump","time":100}`,
+					`{"action":"jump","time":100}`,
+					`{"action":"jump","time":100}`,
+				}
+				addMultipleActions(averager, actions, !delay)
+				stats := averager.GetStats()
+				Expect(stats).To(Equal(`[{"action":"jump","avg":100,"count":4,"sum":400,"min":100,"max":100,"variance":0,"stddev":0}]`))
+
+				averager = actionaverager.NewActionAverager()
+				actions = []string{
+					`{"action":"jump","time":10}`,
+					`{"action":"jump","time":190}`,
+					`{"action":"jump","time":10}`,
+					`{"action":"jump","time":190}`,
+				}
+				addMultipleActions(averager, actions, !delay)
+				stats = averager.GetStats()
+				Expect(stats).To(Equal(`[{"action":"jump","avg":100,"count":4,"sum":400,"min":10,"max":190,"variance":8100,"stddev":90}]`))
 			})
 
 			It("should not return anything if GetStats is called without AddAction being called", func() {
@@ -193,14 +220,14 @@ var _ = Describe("action-averager tests", func() {
 
 				stats := averager.GetStats()
 				expStats := []string{
-					`{"action":"bike","avg":10}`,
-					`{"action":"swim","avg":20}`,
-					`{"action":"run","avg":30}`,
-					`{"action":"hop","avg":40}`,
-					`{"action":"skip","avg":50}`,
-					`{"action":"jump","avg":60}`,
+					`{"action":"bike","avg":10,"count":1,"sum":10,"min":10,"max":10,"variance":0,"stddev":0}`,
+					`{"action":"hop","avg":40,"count":1,"sum":40,"min":40,"max":40,"variance":0,"stddev":0}`,
+					`{"action":"jump","avg":60,"count":1,"sum":60,"min":60,"max":60,"variance":0,"stddev":0}`,
+					`{"action":"run","avg":30,"count":1,"sum":30,"min":30,"max":30,"variance":0,"stddev":0}`,
+					`{"action":"skip","avg":50,"count":1,"sum":50,"min":50,"max":50,"variance":0,"stddev":0}`,
+					`{"action":"swim","avg":20,"count":1,"sum":20,"min":20,"max":20,"variance":0,"stddev":0}`,
 				}
-				verifyMultipleDifferentStats(stats, expStats, verifyAll)
+				verifyStats(stats, expStats)
 			})
 
 			It("should average multiple inputs of the same action concurrently", func() {
@@ -224,7 +251,7 @@ var _ = Describe("action-averager tests", func() {
 				<-done
 
 				stats := averager.GetStats()
-				Expect(stats).To(Equal(`[{"action":"bike","avg":35}]`))
+				Expect(stats).To(Equal(`[{"action":"bike","avg":35,"count":6,"sum":210,"min":10,"max":60,"variance":291.6666666666667,"stddev":17.07825127659933}]`))
 			})
 
 			It("should average multiple inputs of different actions concurrently", func() {
@@ -249,10 +276,10 @@ var _ = Describe("action-averager tests", func() {
 
 				stats := averager.GetStats()
 				expStats := []string{
-					`{"action":"bike","avg":30}`,
-					`{"action":"swim","avg":40}`,
+					`{"action":"bike","avg":30,"count":3,"sum":90,"min":10,"max":50,"variance":266.6666666666667,"stddev":16.32993161855452}`,
+					`{"action":"swim","avg":40,"count":3,"sum":120,"min":20,"max":60,"variance":266.6666666666667,"stddev":16.32993161855452}`,
 				}
-				verifyMultipleDifferentStats(stats, expStats, verifyAll)
+				verifyStats(stats, expStats)
 			})
 
 			It("should handle a mix of single and multiple inputs to different actions concurrently", func() {
@@ -277,12 +304,12 @@ var _ = Describe("action-averager tests", func() {
 
 				stats := averager.GetStats()
 				expStats := []string{
-					`{"action":"bike","avg":30}`,
-					`{"action":"swim","avg":30}`,
-					`{"action":"run","avg":30}`,
-					`{"action":"walk","avg":60}`,
+					`{"action":"bike","avg":30,"count":2,"sum":60,"min":10,"max":50,"variance":400,"stddev":20}`,
+					`{"action":"run","avg":30,"count":1,"sum":30,"min":30,"max":30,"variance":0,"stddev":0}`,
+					`{"action":"swim","avg":30,"count":2,"sum":60,"min":20,"max":40,"variance":100,"stddev":10}`,
+					`{"action":"walk","avg":60,"count":1,"sum":60,"min":60,"max":60,"variance":0,"stddev":0}`,
 				}
-				verifyMultipleDifferentStats(stats, expStats, verifyAll)
+				verifyStats(stats, expStats)
 			})
 
 			It("should handle concurrent calls to AddAction and GetStats", func() {
@@ -304,10 +331,10 @@ var _ = Describe("action-averager tests", func() {
 					addMultipleActions(averager, actions0, delay)
 					stats := averager.GetStats()
 					minExpStats := []string{
-						`{"action":"bike","avg":100}`,
-						`{"action":"run","avg":90}`,
+						`{"action":"bike","avg":100,"count":1,"sum":100,"min":100,"max":100,"variance":0,"stddev":0}`,
+						`{"action":"run","avg":90,"count":1,"sum":90,"min":90,"max":90,"variance":0,"stddev":0}`,
 					}
-					verifyMultipleDifferentStats(stats, minExpStats, !verifyAll)
+					verifyMinimumStats(stats, minExpStats)
 					addMultipleActions(averager, actions1, delay)
 				}
 
@@ -319,17 +346,17 @@ var _ = Describe("action-averager tests", func() {
 					err := averager.AddAction(`{"action":"walk","time":40}`)
 					Expect(err).NotTo(HaveOccurred())
 					stats := averager.GetStats()
-					minExpStats0 := []string{`{"action":"walk","avg":40}`}
-					verifyMultipleDifferentStats(stats, minExpStats0, !verifyAll)
+					minExpStats0 := []string{`{"action":"walk","avg":40,"count":1,"sum":40,"min":40,"max":40,"variance":0,"stddev":0}`}
+					verifyMinimumStats(stats, minExpStats0)
 
 					addMultipleActions(averager, actions, delay)
 					stats = averager.GetStats()
 					minExpStats1 := []string{
-						`{"action":"walk","avg":40}`,
-						`{"action":"skip","avg":60}`,
-						`{"action":"jump","avg":50}`,
+						`{"action":"jump","avg":50,"count":1,"sum":50,"min":50,"max":50,"variance":0,"stddev":0}`,
+						`{"action":"skip","avg":60,"count":1,"sum":60,"min":60,"max":60,"variance":0,"stddev":0}`,
+						`{"action":"walk","avg":40,"count":1,"sum":40,"min":40,"max":40,"variance":0,"stddev":0}`,
 					}
-					verifyMultipleDifferentStats(stats, minExpStats1, !verifyAll)
+					verifyMinimumStats(stats, minExpStats1)
 				}
 
 				done := make(chan bool)
@@ -342,16 +369,16 @@ var _ = Describe("action-averager tests", func() {
 
 				stats := averager.GetStats()
 				expStats := []string{
-					`{"action":"bike","avg":100}`,
-					`{"action":"run","avg":90}`,
-					`{"action":"walk","avg":40}`,
-					`{"action":"skip","avg":60}`,
-					`{"action":"jump","avg":50}`,
-					`{"action":"swim","avg":80}`,
-					`{"action":"hop","avg":70}`,
+					`{"action":"bike","avg":100,"count":1,"sum":100,"min":100,"max":100,"variance":0,"stddev":0}`,
+					`{"action":"hop","avg":70,"count":1,"sum":70,"min":70,"max":70,"variance":0,"stddev":0}`,
+					`{"action":"jump","avg":50,"count":1,"sum":50,"min":50,"max":50,"variance":0,"stddev":0}`,
+					`{"action":"run","avg":90,"count":1,"sum":90,"min":90,"max":90,"variance":0,"stddev":0}`,
+					`{"action":"skip","avg":60,"count":1,"sum":60,"min":60,"max":60,"variance":0,"stddev":0}`,
+					`{"action":"swim","avg":80,"count":1,"sum":80,"min":80,"max":80,"variance":0,"stddev":0}`,
+					`{"action":"walk","avg":40,"count":1,"sum":40,"min":40,"max":40,"variance":0,"stddev":0}`,
 				}
 				// NOTE: full verification can happen here since all actions have stopped being added
-				verifyMultipleDifferentStats(stats, expStats, verifyAll)
+				verifyStats(stats, expStats)
 			})
 		})
 	})
@@ -361,6 +388,7 @@ var _ = Describe("action-averager tests", func() {
 			err := averager.AddAction("")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("unexpected end of JSON input"))
+			verifyValidationError(err, actionaverager.ErrInvalidJSON, "")
 			stats := averager.GetStats()
 			Expect(stats).To(Equal(emptyStats))
 		})
@@ -369,6 +397,9 @@ var _ = Describe("action-averager tests", func() {
 			err := averager.AddAction("string")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("invalid character 's' looking for beginning of value"))
+			verifyValidationError(err, actionaverager.ErrInvalidJSON, "")
+			var syntaxErr *json.SyntaxError
+			Expect(errors.As(err, &syntaxErr)).To(BeTrue())
 			stats := averager.GetStats()
 			Expect(stats).To(Equal(emptyStats))
 		})
@@ -377,12 +408,14 @@ var _ = Describe("action-averager tests", func() {
 			err := averager.AddAction(`{"action":123,"time":"run"}`)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal(`action field is not a string in input {"action":123,"time":"run"}, rejecting`))
+			verifyValidationError(err, actionaverager.ErrWrongType, "action")
 			stats := averager.GetStats()
 			Expect(stats).To(Equal(emptyStats))
 
 			err = averager.AddAction(`{"action":"run","time":"run"}`)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal(`time field is not a number in input {"action":"run","time":"run"}, rejecting`))
+			verifyValidationError(err, actionaverager.ErrWrongType, "time")
 			stats = averager.GetStats()
 			Expect(stats).To(Equal(emptyStats))
 		})
@@ -391,12 +424,14 @@ var _ = Describe("action-averager tests", func() {
 			err := averager.AddAction(`{"action":"run","tome":123}`)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal(`input {"action":"run","tome":123} is missing "time" field, rejecting`))
+			verifyValidationError(err, actionaverager.ErrMissingField, "time")
 			stats := averager.GetStats()
 			Expect(stats).To(Equal(emptyStats))
 
 			err = averager.AddAction(`{"actoin":"run","time":123}`)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal(`input {"actoin":"run","time":123} is missing "action" field, rejecting`))
+			verifyValidationError(err, actionaverager.ErrMissingField, "action")
 			stats = averager.GetStats()
 			Expect(stats).To(Equal(emptyStats))
 		})
@@ -405,6 +440,7 @@ var _ = Describe("action-averager tests", func() {
 			err := averager.AddAction(`{"action":"run","time":123,"extra":true}`)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal(`unexpected number of fields, 3, in input {"action":"run","time":123,"extra":true}, expect 2, rejecting`))
+			verifyValidationError(err, actionaverager.ErrFieldCount, "")
 			stats := averager.GetStats()
 			Expect(stats).To(Equal(emptyStats))
 		})
@@ -413,6 +449,7 @@ var _ = Describe("action-averager tests", func() {
 			err := averager.AddAction(`{"action":"run","action":"jump"}`)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal(`unexpected number of fields, 1, in input {"action":"run","action":"jump"}, expect 2, rejecting`))
+			verifyValidationError(err, actionaverager.ErrFieldCount, "")
 			stats := averager.GetStats()
 			Expect(stats).To(Equal(emptyStats))
 		})
@@ -421,6 +458,19 @@ var _ = Describe("action-averager tests", func() {
 			err := averager.AddAction(`{"action":"bike","time":-1}`)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal(`negative time value for input {"action":"bike","time":-1}, rejecting`))
+			verifyValidationError(err, actionaverager.ErrNegativeTime, "time")
+			var validationErr *actionaverager.ValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue())
+			Expect(validationErr.Input).To(Equal(`{"action":"bike","time":-1}`))
+			stats := averager.GetStats()
+			Expect(stats).To(Equal(emptyStats))
+		})
+
+		It("should fail if a json value other than an object is given as input", func() {
+			err := averager.AddAction(`["run",123]`)
+			Expect(err).To(HaveOccurred())
+			verifyValidationError(err, actionaverager.ErrNotObject, "")
+			Expect(errors.Is(err, actionaverager.ErrInvalidJSON)).To(BeFalse())
 			stats := averager.GetStats()
 			Expect(stats).To(Equal(emptyStats))
 		})
@@ -440,10 +490,10 @@ var _ = Describe("action-averager tests", func() {
 			Expect(err).NotTo(HaveOccurred())
 			stats := averager.GetStats()
 			expStats := []string{
-				`{"action":"run","avg":25}`,
-				`{"action":"jump","avg":10}`,
+				`{"action":"jump","avg":10,"count":1,"sum":10,"min":10,"max":10,"variance":0,"stddev":0}`,
+				`{"action":"run","avg":25,"count":2,"sum":50,"min":20,"max":30,"variance":25,"stddev":5}`,
 			}
-			verifyMultipleDifferentStats(stats, expStats, verifyAll)
+			verifyStats(stats, expStats)
 		})
 	})
 })
@@ -0,0 +1,159 @@
+package actionaverager_test
+
+import (
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const (
+	asyncQueueSize  = 2
+	asyncNumWorkers = 1
+)
+
+// gatedAverager is an ActionAverager that blocks every AddAction until the gate is opened, so tests can fill the
+// queue of an AsyncActionAverage
+type gatedAverager struct {
+	actionaverager.ActionAverager
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func newGatedAverager() *gatedAverager {
+	return &gatedAverager{
+		ActionAverager: actionaverager.NewActionAverager(),
+		started:        make(chan struct{}, 1),
+		gate:           make(chan struct{}),
+	}
+}
+
+func (ga *gatedAverager) AddAction(input string) error {
+	select {
+	case ga.started <- struct{}{}:
+	default:
+	}
+	<-ga.gate
+	return ga.ActionAverager.AddAction(input)
+}
+
+var _ = Describe("action-averager async tests", func() {
+	var gated *gatedAverager
+	var errsMux sync.Mutex
+	var errs []error
+	errHandler := func(err error) {
+		errsMux.Lock()
+		defer errsMux.Unlock()
+		errs = append(errs, err)
+	}
+
+	BeforeEach(func() {
+		gated = newGatedAverager()
+		errs = nil
+	})
+
+	// newFullAverager returns an averager whose worker is blocked on the first action and whose queue is full
+	newFullAverager := func(policy actionaverager.QueuePolicy) *actionaverager.AsyncActionAverage {
+		averager, err := actionaverager.NewAsyncActionAverager(gated, asyncQueueSize, asyncNumWorkers, policy, errHandler)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(averager.AddAction(`{"action":"run","time":10}`)).To(Succeed())
+		Eventually(gated.started).Should(Receive())
+		Expect(averager.AddAction(`{"action":"run","time":20}`)).To(Succeed())
+		Expect(averager.AddAction(`{"action":"run","time":30}`)).To(Succeed())
+		return averager
+	}
+
+	It("should add every queued action once flushed", func() {
+		close(gated.gate)
+		averager, err := actionaverager.NewAsyncActionAverager(gated, asyncQueueSize, 4, actionaverager.QueueBlock, errHandler)
+		Expect(err).NotTo(HaveOccurred())
+		defer averager.Close()
+
+		actions := []string{
+			`{"action":"run","time":50}`,
+			`{"action":"jump","time":10}`,
+			`{"action":"run","time":70}`,
+		}
+		addMultipleActions(averager, actions, !delay)
+		averager.Flush()
+		stats := averager.GetStats()
+		expStats := []string{
+			`{"action":"jump","avg":10,"count":1,"sum":10,"min":10,"max":10,"variance":0,"stddev":0}`,
+			`{"action":"run","avg":60,"count":2,"sum":120,"min":50,"max":70,"variance":100,"stddev":10}`,
+		}
+		verifyStats(stats, expStats)
+		Expect(averager.Dropped()).To(BeZero())
+	})
+
+	It("should pass rejected actions to the error handler", func() {
+		close(gated.gate)
+		averager, err := actionaverager.NewAsyncActionAverager(gated, asyncQueueSize, asyncNumWorkers, actionaverager.QueueBlock, errHandler)
+		Expect(err).NotTo(HaveOccurred())
+		defer averager.Close()
+
+		Expect(averager.AddAction(`{"action":"run","time":-1}`)).To(Succeed())
+		averager.Flush()
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Error()).To(Equal(`negative time value for input {"action":"run","time":-1}, rejecting`))
+		Expect(averager.GetStats()).To(Equal(emptyStats))
+	})
+
+	It("should drop the newest action when the queue is full", func() {
+		averager := newFullAverager(actionaverager.QueueDropNewest)
+		Expect(averager.AddAction(`{"action":"run","time":40}`)).To(MatchError(actionaverager.ErrQueueFull))
+		Expect(averager.Dropped()).To(Equal(uint64(1)))
+
+		close(gated.gate)
+		Expect(averager.Close()).To(Succeed())
+		Expect(averager.GetStats()).To(ContainSubstring(`{"action":"run","avg":20,"count":3,"sum":60,"min":10,"max":30,`))
+	})
+
+	It("should drop the oldest queued action when the queue is full", func() {
+		averager := newFullAverager(actionaverager.QueueDropOldest)
+		Expect(averager.AddAction(`{"action":"run","time":40}`)).To(Succeed())
+		Expect(averager.Dropped()).To(Equal(uint64(1)))
+
+		close(gated.gate)
+		averager.Flush()
+		Expect(averager.GetStats()).To(ContainSubstring(`{"action":"run","avg":26.666666666666668,"count":3,"sum":80,"min":10,"max":40,`))
+		Expect(averager.Close()).To(Succeed())
+	})
+
+	It("should block until there is room in the queue", func() {
+		averager := newFullAverager(actionaverager.QueueBlock)
+		added := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(added)
+			Expect(averager.AddAction(`{"action":"run","time":40}`)).To(Succeed())
+		}()
+		Consistently(added).ShouldNot(BeClosed())
+
+		close(gated.gate)
+		Eventually(added).Should(BeClosed())
+		averager.Flush()
+		Expect(averager.GetStats()).To(ContainSubstring(`"count":4,`))
+		Expect(averager.Dropped()).To(BeZero())
+		Expect(averager.Close()).To(Succeed())
+	})
+
+	It("should drain the queue on close and reject actions after", func() {
+		averager := newFullAverager(actionaverager.QueueBlock)
+		close(gated.gate)
+		Expect(averager.Close()).To(Succeed())
+		Expect(averager.GetStats()).To(ContainSubstring(`"count":3,`))
+		Expect(averager.AddAction(`{"action":"run","time":40}`)).To(MatchError(actionaverager.ErrClosed))
+		Expect(averager.Close()).To(Succeed())
+	})
+
+	It("should fail to create an averager with an invalid queue", func() {
+		_, err := actionaverager.NewAsyncActionAverager(gated, 0, asyncNumWorkers, actionaverager.QueueBlock, nil)
+		Expect(err).To(HaveOccurred())
+		_, err = actionaverager.NewAsyncActionAverager(gated, asyncQueueSize, 0, actionaverager.QueueBlock, nil)
+		Expect(err).To(HaveOccurred())
+		_, err = actionaverager.NewAsyncActionAverager(gated, asyncQueueSize, asyncNumWorkers, actionaverager.QueuePolicy(-1), nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,71 @@
+package actionaverager_test
+
+import (
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const atomicNumGoroutines = 100
+
+var _ = Describe("action-averager atomic tests", func() {
+	var averager actionaverager.ActionAverager
+	BeforeEach(func() {
+		averager = actionaverager.NewAtomicActionAverager()
+	})
+
+	It("should average the times of each action", func() {
+		actions := []string{
+			`{"action":"run","time":50}`,
+			`{"action":"jump","time":10}`,
+			`{"action":"run","time":70}`,
+		}
+		addMultipleActions(averager, actions, !delay)
+		Expect(actionaverager.AddSample(averager, "jump", 0)).To(Succeed())
+		stats := averager.GetStats()
+		expStats := []string{
+			`{"action":"jump","avg":5,"count":2,"sum":10,"min":0,"max":10}`,
+			`{"action":"run","avg":60,"count":2,"sum":120,"min":50,"max":70}`,
+		}
+		verifyStats(stats, expStats)
+	})
+
+	It("should have exact stats once concurrent adds have returned", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < atomicNumGoroutines; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				// NOTE: small integer times so that the sum is exact in any order
+				Expect(actionaverager.AddSample(averager, "run", float64(i))).To(Succeed())
+				Expect(actionaverager.AddSample(averager, "jump", 1)).To(Succeed())
+			}(i)
+		}
+		wg.Wait()
+
+		stats := averager.GetStats()
+		expStats := []string{
+			`{"action":"jump","avg":1,"count":100,"sum":100,"min":1,"max":1}`,
+			`{"action":"run","avg":49.5,"count":100,"sum":4950,"min":0,"max":99}`,
+		}
+		verifyStats(stats, expStats)
+	})
+
+	It("should not return anything if GetStats is called without AddAction being called", func() {
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(emptyStats))
+	})
+
+	It("should reject the same input as AddAction", func() {
+		err := averager.AddAction(`{"action":"run","time":"20"}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal(`time field is not a number in input {"action":"run","time":"20"}, rejecting`))
+		Expect(actionaverager.AddSample(averager, "run", -1)).NotTo(Succeed())
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(emptyStats))
+	})
+})
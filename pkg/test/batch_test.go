@@ -0,0 +1,68 @@
+package actionaverager_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+var _ = Describe("action-averager batch tests", func() {
+	var averager *actionaverager.ActionAverage
+	BeforeEach(func() {
+		averager = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	})
+
+	It("should add every action in a batch", func() {
+		err := averager.AddActions(`[{"action":"run","time":50},{"action":"jump","time":10},{"action":"run","time":70}]`)
+		Expect(err).NotTo(HaveOccurred())
+		stats := averager.GetStats()
+		expStats := []string{
+			`{"action":"jump","avg":10,"count":1,"sum":10,"min":10,"max":10,"variance":0,"stddev":0}`,
+			`{"action":"run","avg":60,"count":2,"sum":120,"min":50,"max":70,"variance":100,"stddev":10}`,
+		}
+		verifyStats(stats, expStats)
+	})
+
+	It("should handle an empty batch", func() {
+		err := averager.AddActions(`[]`)
+		Expect(err).NotTo(HaveOccurred())
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(emptyStats))
+	})
+
+	It("should add the valid actions and report the index of each rejected action", func() {
+		err := averager.AddActions(`[{"action":"run","time":50},{"action":"run","time":-1},"run",{"action":"run","time":30,"extra":1}]`)
+		Expect(err).To(HaveOccurred())
+
+		var batchErr *actionaverager.BatchError
+		Expect(errors.As(err, &batchErr)).To(BeTrue())
+		Expect(batchErr.Errors).To(HaveLen(3))
+		Expect(batchErr.Errors[0].Index).To(Equal(1))
+		Expect(batchErr.Errors[0].Err.Error()).To(Equal(`negative time value for input {"action":"run","time":-1}, rejecting`))
+		Expect(batchErr.Errors[1].Index).To(Equal(2))
+		Expect(batchErr.Errors[1].Err.Error()).To(Equal(`unable to convert input "run" to internal data, rejecting`))
+		Expect(errors.Is(batchErr.Errors[1], actionaverager.ErrNotObject)).To(BeTrue())
+		Expect(batchErr.Errors[2].Index).To(Equal(3))
+		Expect(batchErr.Errors[2].Err.Error()).To(Equal(`unexpected number of fields, 3, in input {"action":"run","time":30,"extra":1}, expect 2, rejecting`))
+
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(`[{"action":"run","avg":50,"count":1,"sum":50,"min":50,"max":50,"variance":0,"stddev":0}]`))
+	})
+
+	It("should not add anything if the batch is not a json array", func() {
+		err := averager.AddActions(`{"action":"run","time":50}`)
+		Expect(err).To(HaveOccurred())
+		var batchErr *actionaverager.BatchError
+		Expect(errors.As(err, &batchErr)).To(BeFalse())
+
+		err = averager.AddActions(`[{"action":"run","time":50}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("unexpected end of JSON input"))
+
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(emptyStats))
+	})
+})
@@ -0,0 +1,157 @@
+package actionaverager_test
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const (
+	benchAction = "jump"
+	benchTime   = 111
+	benchInput  = `{"action":"jump","time":111}`
+)
+
+func BenchmarkAddAction(b *testing.B) {
+	averager := actionaverager.NewActionAverager()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := averager.AddAction(benchInput); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAddSample(b *testing.B) {
+	averager := actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := averager.AddSample(benchAction, benchTime); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAddDuration(b *testing.B) {
+	averager := actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := averager.AddDuration(benchAction, benchTime*time.Millisecond); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// NOTE: the parallel benchmarks add to a different action from each goroutine, like a service timing many
+// actions, and to a single action, which is the worst case since every goroutine contends on one shard. Run them
+// with -cpu=1,2,4,8 to see throughput scale with GOMAXPROCS. Errors are reported with Error instead of Fatal,
+// since Fatal can only be called from the benchmark goroutine.
+func BenchmarkAddSampleParallel(b *testing.B) {
+	averager := actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	var goroutine int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		actStr := benchAction + strconv.FormatInt(atomic.AddInt64(&goroutine, 1), 10)
+		for pb.Next() {
+			if err := averager.AddSample(actStr, benchTime); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkAddSampleParallelSingleAction(b *testing.B) {
+	averager := actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := averager.AddSample(benchAction, benchTime); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkAddActionParallel(b *testing.B) {
+	averager := actionaverager.NewActionAverager()
+	var goroutine int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		input := fmt.Sprintf(`{"action":"%s%d","time":%d}`, benchAction, atomic.AddInt64(&goroutine, 1), benchTime)
+		for pb.Next() {
+			if err := averager.AddAction(input); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkGetStatsParallel(b *testing.B) {
+	averager := actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	for i := 0; i < 100; i++ {
+		if err := averager.AddSample(benchAction+strconv.Itoa(i), benchTime); err != nil {
+			b.Fatal(err)
+		}
+	}
+	var ops int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// NOTE: one in every 4 operations reads the stats while the rest add, like a service that is scraped. The
+		// reads are chosen per operation and not per goroutine so there are reads with any GOMAXPROCS.
+		for pb.Next() {
+			if atomic.AddInt64(&ops, 1)%4 == 0 {
+				averager.GetStats()
+				continue
+			}
+			if err := averager.AddSample(benchAction, benchTime); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkAtomicAddSampleParallel(b *testing.B) {
+	averager := actionaverager.NewAtomicActionAverager().(*actionaverager.AtomicActionAverage)
+	var goroutine int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		actStr := benchAction + strconv.FormatInt(atomic.AddInt64(&goroutine, 1), 10)
+		for pb.Next() {
+			if err := averager.AddSample(actStr, benchTime); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// NOTE: compare with BenchmarkAddSampleParallelSingleAction, every goroutine contends on the cell of one action
+func BenchmarkAtomicAddSampleParallelSingleAction(b *testing.B) {
+	averager := actionaverager.NewAtomicActionAverager().(*actionaverager.AtomicActionAverage)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := averager.AddSample(benchAction, benchTime); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
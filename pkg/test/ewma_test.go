@@ -0,0 +1,84 @@
+package actionaverager_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const halfLife = time.Minute
+
+var _ = Describe("action-averager ewma tests", func() {
+	var clock *fakeClock
+	var averager actionaverager.ActionAverager
+	BeforeEach(func() {
+		var err error
+		clock = newFakeClock()
+		averager, err = actionaverager.NewEWMAActionAverager(halfLife, clock.Now)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should weight times added at the same instant equally", func() {
+		actions := []string{
+			`{"action":"run","time":10}`,
+			`{"action":"run","time":30}`,
+			`{"action":"jump","time":5}`,
+		}
+		addMultipleActions(averager, actions, !delay)
+		stats := averager.GetStats()
+		expStats := []string{
+			`{"action":"jump","avg":5,"count":1}`,
+			`{"action":"run","avg":20,"count":2}`,
+		}
+		verifyStats(stats, expStats)
+	})
+
+	It("should halve the weight of older times every half-life", func() {
+		err := averager.AddAction(`{"action":"run","time":100}`)
+		Expect(err).NotTo(HaveOccurred())
+		clock.Advance(halfLife)
+		err = averager.AddAction(`{"action":"run","time":200}`)
+		Expect(err).NotTo(HaveOccurred())
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(`[{"action":"run","avg":166.66666666666666,"count":2}]`))
+
+		clock.Advance(halfLife)
+		err = averager.AddAction(`{"action":"run","time":0}`)
+		Expect(err).NotTo(HaveOccurred())
+		stats = averager.GetStats()
+		Expect(stats).To(Equal(`[{"action":"run","avg":71.42857142857143,"count":3}]`))
+	})
+
+	It("should follow a change in behaviour after a few half-lives", func() {
+		err := averager.AddAction(`{"action":"run","time":1000}`)
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < 10; i++ {
+			clock.Advance(halfLife)
+			err = averager.AddAction(`{"action":"run","time":10}`)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		stats := averager.GetStats()
+		Expect(stats).To(HavePrefix(`[{"action":"run","avg":10.4`))
+	})
+
+	It("should not return anything if GetStats is called without AddAction being called", func() {
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(emptyStats))
+	})
+
+	It("should reject the same input as AddAction", func() {
+		err := averager.AddAction(`{"action":"run","tome":123}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal(`input {"action":"run","tome":123} is missing "time" field, rejecting`))
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(emptyStats))
+	})
+
+	It("should fail to create an averager with an invalid half-life", func() {
+		_, err := actionaverager.NewEWMAActionAverager(0, clock.Now)
+		Expect(err).To(HaveOccurred())
+	})
+})
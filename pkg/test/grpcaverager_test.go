@@ -0,0 +1,151 @@
+package actionaverager_test
+
+import (
+	"context"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/action-averager/pkg/actionaverager"
+	"github.com/action-averager/pkg/grpcaverager"
+	"github.com/action-averager/pkg/grpcaverager/averagerpb"
+)
+
+const (
+	bufconnSize   = 1024 * 1024
+	bufconnTarget = "passthrough:///bufconn"
+	watchInterval = 10 * time.Millisecond
+)
+
+func startGRPCServer(averager actionaverager.ActionAverager) (*grpcaverager.Client, func()) {
+	listener := bufconn.Listen(bufconnSize)
+	server := grpc.NewServer()
+	averagerpb.RegisterActionAveragerServer(server, grpcaverager.NewServer(averager))
+	// NOTE: the Serve error is checked in stop instead of the goroutine, so nothing is asserted after the spec ends
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	conn, err := grpc.NewClient(bufconnTarget,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	Expect(err).NotTo(HaveOccurred())
+
+	return grpcaverager.NewClient(conn), func() {
+		Expect(conn.Close()).To(Succeed())
+		server.Stop()
+		Expect(<-serveErr).To(Succeed())
+	}
+}
+
+var _ = Describe("action-averager grpc tests", func() {
+	var ctx context.Context
+	var averager actionaverager.ActionAverager
+	var client *grpcaverager.Client
+	var stop func()
+	BeforeEach(func() {
+		ctx = context.Background()
+		averager = actionaverager.NewActionAverager()
+		client, stop = startGRPCServer(averager)
+	})
+	AfterEach(func() {
+		stop()
+	})
+
+	It("should add single actions and get the stats", func() {
+		Expect(client.AddSample(ctx, "run", 10)).To(Succeed())
+		Expect(client.AddSample(ctx, "run", 30)).To(Succeed())
+
+		stats, err := client.GetStats(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats).To(Equal(averager.(*actionaverager.ActionAverage).GetStatsList()))
+		Expect(stats).To(HaveLen(1))
+		Expect(stats[0].Average).To(Equal(float64(20)))
+	})
+
+	It("should reject invalid actions with an invalid argument status", func() {
+		err := client.AddSample(ctx, "run", -1)
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		Expect(averager.GetStats()).To(Equal(emptyStats))
+	})
+
+	It("should return server error statuses when adding fails for a reason other than the input", func() {
+		expCodes := map[error]codes.Code{
+			actionaverager.ErrQueueFull: codes.ResourceExhausted,
+			actionaverager.ErrClosed:    codes.Internal,
+		}
+		for err, expCode := range expCodes {
+			failingClient, failingStop := startGRPCServer(&failingAverager{err: err})
+			addErr := failingClient.AddSample(ctx, "run", 10)
+			_, streamErr := failingClient.AddSamples(ctx, []*averagerpb.AddActionRequest{{Action: "run", Time: 10}})
+			failingStop()
+			Expect(status.Code(addErr)).To(Equal(expCode))
+			Expect(status.Code(streamErr)).To(Equal(expCode))
+		}
+	})
+
+	It("should add a stream of actions and report rejected actions by index", func() {
+		resp, err := client.AddSamples(ctx, []*averagerpb.AddActionRequest{
+			{Action: "run", Time: 10},
+			{Action: "run", Time: -10},
+			{Action: "jump", Time: 5},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.GetAccepted()).To(Equal(int64(2)))
+		Expect(resp.GetRejected()).To(HaveLen(1))
+		Expect(resp.GetRejected()[0].GetIndex()).To(Equal(int64(1)))
+
+		expStats := []string{
+			`{"action":"jump","avg":5,"count":1,"sum":5,"min":5,"max":5,"variance":0,"stddev":0}`,
+			`{"action":"run","avg":10,"count":1,"sum":10,"min":10,"max":10,"variance":0,"stddev":0}`,
+		}
+		verifyStats(averager.GetStats(), expStats)
+	})
+
+	It("should stream the stats whenever they change", func() {
+		watchCtx, cancel := context.WithCancel(ctx)
+		statsCh := make(chan []actionaverager.ActionStats, 10)
+		done := make(chan error)
+		go func() {
+			done <- client.WatchStats(watchCtx, watchInterval, func(stats []actionaverager.ActionStats) {
+				statsCh <- stats
+			})
+		}()
+		Eventually(statsCh).Should(Receive(BeEmpty()))
+
+		Expect(client.AddSample(ctx, "run", 10)).To(Succeed())
+		var stats []actionaverager.ActionStats
+		Eventually(statsCh).Should(Receive(&stats))
+		Expect(stats).To(HaveLen(1))
+		Expect(stats[0].Action).To(Equal("run"))
+
+		cancel()
+		Eventually(done).Should(Receive(MatchError(context.Canceled)))
+	})
+
+	It("should serve averagers without typed stats or subscriptions", func() {
+		ewmaAverager, err := actionaverager.NewEWMAActionAverager(halfLife, newFakeClock().Now)
+		Expect(err).NotTo(HaveOccurred())
+		ewmaClient, ewmaStop := startGRPCServer(ewmaAverager)
+		defer ewmaStop()
+
+		Expect(ewmaClient.AddSample(ctx, "run", 10)).To(Succeed())
+		stats, err := ewmaClient.GetStats(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats).To(Equal([]actionaverager.ActionStats{{Action: "run", Average: 10, Count: 1}}))
+
+		err = ewmaClient.WatchStats(ctx, watchInterval, func([]actionaverager.ActionStats) {})
+		Expect(status.Code(err)).To(Equal(codes.Unimplemented))
+	})
+})
@@ -0,0 +1,139 @@
+package actionaverager_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+var hierarchyActions = []string{
+	`{"action":"checkout.payment.authorize","time":10}`,
+	`{"action":"checkout.payment.authorize","time":30}`,
+	`{"action":"checkout.payment.capture","time":20}`,
+	`{"action":"checkout.cart","time":40}`,
+	`{"action":"search","time":5}`,
+}
+
+var _ = Describe("action-averager hierarchy tests", func() {
+	var averager *actionaverager.ActionAverage
+	BeforeEach(func() {
+		averager = newOptionsAverager(actionaverager.WithHierarchy())
+		addMultipleActions(averager, hierarchyActions, !delay)
+	})
+
+	It("should roll up every action under a prefix", func() {
+		stats, ok := averager.GetPrefixStats("checkout.payment")
+		Expect(ok).To(BeTrue())
+		Expect(stats).To(Equal(actionaverager.ActionStats{
+			Action:   "checkout.payment",
+			Average:  20,
+			Count:    3,
+			Sum:      60,
+			Min:      10,
+			Max:      30,
+			Variance: 200.0 / 3,
+			StdDev:   8.16496580927726,
+		}))
+
+		stats, ok = averager.GetPrefixStats("checkout")
+		Expect(ok).To(BeTrue())
+		Expect(stats.Average).To(Equal(float64(25)))
+		Expect(stats.Count).To(Equal(float64(4)))
+		Expect(stats.Variance).To(Equal(float64(125)))
+
+		stats, ok = averager.GetPrefixStats("checkout.payment.capture")
+		Expect(ok).To(BeTrue())
+		actionStats, _ := averager.GetActionStats("checkout.payment.capture")
+		Expect(stats).To(Equal(actionStats))
+	})
+
+	It("should only roll up whole segments", func() {
+		_, ok := averager.GetPrefixStats("checkout.pay")
+		Expect(ok).To(BeFalse())
+		_, ok = averager.GetPrefixStats("checkout.payment.")
+		Expect(ok).To(BeFalse())
+		_, ok = averager.GetPrefixStats("")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should output the stats as a tree", func() {
+		averager = newOptionsAverager(actionaverager.WithHierarchy())
+		Expect(averager.GetStatsTree()).To(Equal("[]"))
+
+		addMultipleActions(averager, []string{`{"action":"c","time":5}`, `{"action":"a.b","time":10}`, `{"action":"a","time":20}`}, !delay)
+		Expect(averager.GetStatsTree()).To(Equal(`[{"name":"a","action":"a","avg":15,"count":2,"sum":30,"min":10,"max":20,"variance":25,"stddev":5,` +
+			`"children":[{"name":"b","action":"a.b","avg":10,"count":1,"sum":10,"min":10,"max":10,"variance":0,"stddev":0}]},` +
+			`{"name":"c","action":"c","avg":5,"count":1,"sum":5,"min":5,"max":5,"variance":0,"stddev":0}]`))
+	})
+
+	It("should add intermediate nodes that were never added as actions", func() {
+		tree := averager.GetStatsTreeList()
+		Expect(tree).To(HaveLen(2))
+		checkout := tree[0]
+		Expect(checkout.Name).To(Equal("checkout"))
+		Expect(checkout.Count).To(Equal(float64(4)))
+		Expect(checkout.Children).To(HaveLen(2))
+		Expect(checkout.Children[0].Action).To(Equal("checkout.cart"))
+
+		payment := checkout.Children[1]
+		Expect(payment.Action).To(Equal("checkout.payment"))
+		prefixStats, _ := averager.GetPrefixStats("checkout.payment")
+		Expect(payment.ActionStats).To(Equal(prefixStats))
+		Expect(payment.Children).To(HaveLen(2))
+		Expect(payment.Children[0].Name).To(Equal("authorize"))
+		Expect(payment.Children[0].Children).To(BeEmpty())
+		Expect(payment.Children[1].Name).To(Equal("capture"))
+		Expect(tree[1].Name).To(Equal("search"))
+
+		// NOTE: the tree does not change GetStats, which still only has the actions that were added
+		Expect(averager.GetStatsList()).To(HaveLen(4))
+	})
+
+	It("should reject actions with empty segments", func() {
+		for _, action := range []string{"", ".checkout", "checkout.", "checkout..cart"} {
+			verifyValidationError(averager.AddSample(action, 10), actionaverager.ErrActionNotAllowed, "action")
+		}
+		err := averager.AddAction(`{"action":"checkout..cart","time":10}`)
+		Expect(err.Error()).To(Equal(`action "checkout..cart" has an empty segment, rejecting`))
+	})
+
+	It("should ignore empty segments of actions merged from an averager without hierarchy", func() {
+		flat := actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		Expect(flat.AddSample("checkout..cart", 20)).To(Succeed())
+		Expect(flat.AddSample(".search", 15)).To(Succeed())
+		Expect(flat.AddSample("", 1)).To(Succeed())
+		averager.Merge(flat)
+
+		stats, ok := averager.GetPrefixStats("checkout.cart")
+		Expect(ok).To(BeTrue())
+		Expect(stats.Count).To(Equal(float64(2)))
+		stats, ok = averager.GetPrefixStats("search")
+		Expect(ok).To(BeTrue())
+		Expect(stats.Average).To(Equal(float64(10)))
+
+		tree := averager.GetStatsTree()
+		Expect(tree).NotTo(ContainSubstring(`"name":""`))
+		Expect(tree).To(ContainSubstring(`{"name":"cart","action":"checkout.cart","avg":30,"count":2,`))
+		Expect(averager.GetStatsTreeList()).To(HaveLen(2))
+	})
+
+	It("should treat action names as flat without hierarchy enabled", func() {
+		averager = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		addMultipleActions(averager, hierarchyActions, !delay)
+		Expect(averager.AddSample("checkout..cart", 10)).To(Succeed())
+
+		_, ok := averager.GetPrefixStats("checkout")
+		Expect(ok).To(BeFalse())
+		stats, ok := averager.GetPrefixStats("checkout.cart")
+		Expect(ok).To(BeTrue())
+		Expect(stats.Count).To(Equal(float64(1)))
+
+		tree := averager.GetStatsTreeList()
+		Expect(tree).To(HaveLen(5))
+		for _, node := range tree {
+			Expect(node.Name).To(Equal(node.Action))
+			Expect(node.Children).To(BeEmpty())
+		}
+	})
+})
@@ -0,0 +1,183 @@
+package actionaverager_test
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+	"github.com/action-averager/pkg/httphandler"
+)
+
+const (
+	maxBodySize = 256
+
+	contentTypeJSON = "application/json"
+)
+
+// failingAverager is an ActionAverager whose AddAction always fails with err
+type failingAverager struct {
+	actionaverager.ActionAverager
+	err error
+}
+
+func (fa *failingAverager) AddAction(string) error {
+	return fa.err
+}
+
+func doRequest(server *httptest.Server, method string, path string, body string) (int, string) {
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	Expect(err).NotTo(HaveOccurred())
+	resp, err := server.Client().Do(req)
+	Expect(err).NotTo(HaveOccurred())
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	Expect(err).NotTo(HaveOccurred())
+	return resp.StatusCode, string(respBody)
+}
+
+var _ = Describe("action-averager http handler tests", func() {
+	var server *httptest.Server
+	BeforeEach(func() {
+		server = httptest.NewServer(httphandler.NewHandler(actionaverager.NewActionAverager(), maxBodySize))
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should add a single action and get the stats", func() {
+		status, _ := doRequest(server, http.MethodPost, httphandler.ActionsPath, `{"action":"run","time":20}`)
+		Expect(status).To(Equal(http.StatusNoContent))
+
+		resp, err := server.Client().Get(server.URL + httphandler.StatsPath)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal(contentTypeJSON))
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal(`[{"action":"run","avg":20,"count":1,"sum":20,"min":20,"max":20,"variance":0,"stddev":0}]`))
+	})
+
+	It("should add a batch of actions and report rejected actions with a bad request", func() {
+		status, body := doRequest(server, http.MethodPost, httphandler.ActionsPath, ` [{"action":"run","time":20},{"action":"run","time":-1}]`)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(body).To(ContainSubstring(`"rejected":[{"index":1,"error":"negative time value for input {\"action\":\"run\",\"time\":-1}, rejecting"}]`))
+
+		status, body = doRequest(server, http.MethodGet, httphandler.StatsPath, "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`[{"action":"run","avg":20,"count":1,"sum":20,"min":20,"max":20,"variance":0,"stddev":0}]`))
+	})
+
+	It("should reject invalid actions with a bad request", func() {
+		status, body := doRequest(server, http.MethodPost, httphandler.ActionsPath, `{"action":"run","tome":123}`)
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(body).To(Equal(`{"error":"input {\"action\":\"run\",\"tome\":123} is missing \"time\" field, rejecting"}`))
+
+		status, _ = doRequest(server, http.MethodPost, httphandler.ActionsPath, "")
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should respond with a server error when adding fails for a reason other than the input", func() {
+		expStatuses := map[error]int{
+			actionaverager.ErrQueueFull: http.StatusServiceUnavailable,
+			actionaverager.ErrClosed:    http.StatusInternalServerError,
+		}
+		for err, expStatus := range expStatuses {
+			failingServer := httptest.NewServer(httphandler.NewHandler(&failingAverager{err: err}, 0))
+			status, body := doRequest(failingServer, http.MethodPost, httphandler.ActionsPath, `{"action":"run","time":20}`)
+			batchStatus, batchBody := doRequest(failingServer, http.MethodPost, httphandler.ActionsPath, `[{"action":"run","time":20}]`)
+			failingServer.Close()
+			Expect(status).To(Equal(expStatus))
+			Expect(body).To(Equal(`{"error":"` + err.Error() + `"}`))
+			Expect(batchStatus).To(Equal(expStatus))
+			Expect(batchBody).To(Equal(body))
+		}
+	})
+
+	It("should reject bodies larger than the max body size", func() {
+		input := `{"action":"` + strings.Repeat("a", maxBodySize) + `","time":1}`
+		status, _ := doRequest(server, http.MethodPost, httphandler.ActionsPath, input)
+		Expect(status).To(Equal(http.StatusRequestEntityTooLarge))
+
+		status, body := doRequest(server, http.MethodGet, httphandler.StatsPath, "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(emptyStats))
+	})
+
+	It("should get the stats of a single action", func() {
+		status, _ := doRequest(server, http.MethodPost, httphandler.ActionsPath, `[{"action":"run","time":20},{"action":"jump","time":10}]`)
+		Expect(status).To(Equal(http.StatusNoContent))
+
+		status, body := doRequest(server, http.MethodGet, httphandler.StatsPath+"/jump", "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"action":"jump","avg":10,"count":1,"sum":10,"min":10,"max":10,"variance":0,"stddev":0}`))
+
+		status, _ = doRequest(server, http.MethodGet, httphandler.StatsPath+"/walk", "")
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	It("should reject unknown paths and methods", func() {
+		status, _ := doRequest(server, http.MethodGet, httphandler.ActionsPath, "")
+		Expect(status).To(Equal(http.StatusMethodNotAllowed))
+		status, _ = doRequest(server, http.MethodPost, httphandler.StatsPath, "")
+		Expect(status).To(Equal(http.StatusMethodNotAllowed))
+		status, _ = doRequest(server, http.MethodGet, "/unknown", "")
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	It("should stream stats as server-sent events", func() {
+		resp, err := server.Client().Get(server.URL + httphandler.EventsPath + "?interval=100ms")
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+
+		reader := bufio.NewReader(resp.Body)
+		readEvent := func() string {
+			line, err := reader.ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			blank, err := reader.ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blank).To(Equal("\n"))
+			return line
+		}
+		Expect(readEvent()).To(Equal("data: " + emptyStats + "\n"))
+
+		status, _ := doRequest(server, http.MethodPost, httphandler.ActionsPath, `{"action":"run","time":20}`)
+		Expect(status).To(Equal(http.StatusNoContent))
+		Expect(readEvent()).To(Equal(`data: [{"action":"run","avg":20,"count":1,"sum":20,"min":20,"max":20,"variance":0,"stddev":0}]` + "\n"))
+	})
+
+	It("should reject invalid event intervals", func() {
+		status, _ := doRequest(server, http.MethodGet, httphandler.EventsPath+"?interval=1ms", "")
+		Expect(status).To(Equal(http.StatusBadRequest))
+		status, _ = doRequest(server, http.MethodGet, httphandler.EventsPath+"?interval=soon", "")
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should handle averagers without batches or single action stats", func() {
+		averager, err := actionaverager.NewEWMAActionAverager(halfLife, newFakeClock().Now)
+		Expect(err).NotTo(HaveOccurred())
+		ewmaServer := httptest.NewServer(httphandler.NewHandler(averager, 0))
+		defer ewmaServer.Close()
+
+		status, _ := doRequest(ewmaServer, http.MethodPost, httphandler.ActionsPath, `[{"action":"run","time":20},{"act":"run"}]`)
+		Expect(status).To(Equal(http.StatusBadRequest))
+
+		status, body := doRequest(ewmaServer, http.MethodGet, httphandler.StatsPath+"/run", "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(`{"action":"run","avg":20,"count":1}`))
+
+		status, _ = doRequest(ewmaServer, http.MethodGet, httphandler.StatsPath+"/jump", "")
+		Expect(status).To(Equal(http.StatusNotFound))
+
+		status, _ = doRequest(ewmaServer, http.MethodGet, httphandler.EventsPath, "")
+		Expect(status).To(Equal(http.StatusNotImplemented))
+	})
+})
@@ -0,0 +1,99 @@
+package actionaverager_test
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const (
+	stopOnError = true
+
+	ndjson = `{"action":"run","time":50}
+{"action":"jump","time":10}
+
+{"action":"run","time":-5}
+{"action":"run","time":70}
+not json
+{"action":"jump","time":30}
+`
+)
+
+var _ = Describe("action-averager ingest tests", func() {
+	var averager actionaverager.ActionAverager
+	BeforeEach(func() {
+		averager = actionaverager.NewActionAverager()
+	})
+
+	It("should add every line of newline delimited json", func() {
+		input := "{\"action\":\"run\",\"time\":50}\r\n{\"action\":\"run\",\"time\":70}"
+		numAdded, err := actionaverager.IngestNDJSON(averager, strings.NewReader(input), stopOnError)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(numAdded).To(Equal(2))
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(`[{"action":"run","avg":60,"count":2,"sum":120,"min":50,"max":70,"variance":100,"stddev":10}]`))
+	})
+
+	It("should keep averaging after rejected lines and report their line numbers", func() {
+		numAdded, err := actionaverager.IngestNDJSON(averager, strings.NewReader(ndjson), !stopOnError)
+		Expect(err).To(HaveOccurred())
+		Expect(numAdded).To(Equal(4))
+
+		var ingestErr *actionaverager.IngestError
+		Expect(errors.As(err, &ingestErr)).To(BeTrue())
+		Expect(ingestErr.Errors).To(HaveLen(2))
+		Expect(ingestErr.Errors[0].Line).To(Equal(4))
+		Expect(ingestErr.Errors[0].Err.Error()).To(Equal(`negative time value for input {"action":"run","time":-5}, rejecting`))
+		Expect(ingestErr.Errors[1].Line).To(Equal(6))
+
+		stats := averager.GetStats()
+		expStats := []string{
+			`{"action":"jump","avg":20,"count":2,"sum":40,"min":10,"max":30,"variance":100,"stddev":10}`,
+			`{"action":"run","avg":60,"count":2,"sum":120,"min":50,"max":70,"variance":100,"stddev":10}`,
+		}
+		verifyStats(stats, expStats)
+	})
+
+	It("should stop at the first rejected line", func() {
+		numAdded, err := actionaverager.IngestNDJSON(averager, strings.NewReader(ndjson), stopOnError)
+		Expect(err).To(HaveOccurred())
+		Expect(numAdded).To(Equal(2))
+
+		var ingestErr *actionaverager.IngestError
+		Expect(errors.As(err, &ingestErr)).To(BeTrue())
+		Expect(ingestErr.Errors).To(HaveLen(1))
+		Expect(ingestErr.Errors[0].Line).To(Equal(4))
+
+		stats := averager.GetStats()
+		expStats := []string{
+			`{"action":"jump","avg":10,"count":1,"sum":10,"min":10,"max":10,"variance":0,"stddev":0}`,
+			`{"action":"run","avg":50,"count":1,"sum":50,"min":50,"max":50,"variance":0,"stddev":0}`,
+		}
+		verifyStats(stats, expStats)
+	})
+
+	It("should stop and return the error if a line is too long to read", func() {
+		input := `{"action":"run","time":50}` + "\n" + strings.Repeat(" ", 2*bufio.MaxScanTokenSize*16) + "\n"
+		numAdded, err := actionaverager.IngestNDJSON(averager, strings.NewReader(input), !stopOnError)
+		Expect(err).To(MatchError(bufio.ErrTooLong))
+		Expect(numAdded).To(Equal(1))
+	})
+
+	It("should return both the rejected lines and the read error if a line is too long to read", func() {
+		input := `{"action":"run","time":-5}` + "\n" + `{"action":"run","time":50}` + "\n" + strings.Repeat(" ", 2*bufio.MaxScanTokenSize*16) + "\n"
+		numAdded, err := actionaverager.IngestNDJSON(averager, strings.NewReader(input), !stopOnError)
+		Expect(err).To(MatchError(bufio.ErrTooLong))
+		Expect(numAdded).To(Equal(1))
+
+		var ingestErr *actionaverager.IngestError
+		Expect(errors.As(err, &ingestErr)).To(BeTrue())
+		Expect(ingestErr.Errors).To(HaveLen(1))
+		Expect(ingestErr.Errors[0].Line).To(Equal(1))
+		Expect(ingestErr.ReadErr).To(Equal(bufio.ErrTooLong))
+	})
+})
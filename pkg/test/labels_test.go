@@ -0,0 +1,157 @@
+package actionaverager_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const maxLabelSets = 4
+
+var labeledActions = []string{
+	`{"action":"jump","time":10,"labels":{"region":"eu","build":"1.0"}}`,
+	`{"action":"jump","time":30,"labels":{"region":"eu","build":"1.1"}}`,
+	`{"action":"jump","time":50,"labels":{"build":"1.1","region":"us"}}`,
+	`{"action":"jump","time":70}`,
+	`{"action":"run","time":5,"labels":{"region":"eu"}}`,
+}
+
+var _ = Describe("action-averager labels tests", func() {
+	var averager *actionaverager.ActionAverage
+	BeforeEach(func() {
+		averager = newOptionsAverager(actionaverager.WithLabels(maxLabelSets))
+		addMultipleActions(averager, labeledActions, !delay)
+	})
+
+	It("should roll up every label set in GetStats", func() {
+		stats := averager.GetStats()
+		expStats := []string{
+			`{"action":"jump","avg":40,"count":4,"sum":160,"min":10,"max":70,"variance":500,"stddev":22.360679774997898}`,
+			`{"action":"run","avg":5,"count":1,"sum":5,"min":5,"max":5,"variance":0,"stddev":0}`,
+		}
+		verifyStats(stats, expStats)
+		Expect(averager.GetStatsGroupedBy()).To(Equal(stats))
+	})
+
+	It("should group by a label and roll up the rest", func() {
+		stats := averager.GetStatsGroupedBy("region")
+		expStats := []string{
+			`{"action":"jump","avg":70,"count":1,"sum":70,"min":70,"max":70,"variance":0,"stddev":0}`,
+			`{"action":"jump","labels":{"region":"eu"},"avg":20,"count":2,"sum":40,"min":10,"max":30,"variance":100,"stddev":10}`,
+			`{"action":"jump","labels":{"region":"us"},"avg":50,"count":1,"sum":50,"min":50,"max":50,"variance":0,"stddev":0}`,
+			`{"action":"run","labels":{"region":"eu"},"avg":5,"count":1,"sum":5,"min":5,"max":5,"variance":0,"stddev":0}`,
+		}
+		verifyStats(stats, expStats)
+	})
+
+	It("should group by many labels", func() {
+		statsList := averager.GetStatsGroupedByList("build", "region")
+		Expect(statsList).To(HaveLen(5))
+		Expect(statsList[1].Labels).To(Equal(map[string]string{"build": "1.0", "region": "eu"}))
+		Expect(statsList[2].Labels).To(Equal(map[string]string{"build": "1.1", "region": "eu"}))
+		Expect(statsList[2].Average).To(Equal(float64(30)))
+
+		statsList = averager.GetStatsGroupedByList("build")
+		Expect(statsList).To(HaveLen(4))
+		Expect(statsList[2].Labels).To(Equal(map[string]string{"build": "1.1"}))
+		Expect(statsList[2].Average).To(Equal(float64(40)))
+	})
+
+	It("should add labeled samples and drop empty label values", func() {
+		labels := map[string]string{"region": "us", "build": ""}
+		Expect(averager.AddLabeledSample("run", 150, labels)).To(Succeed())
+		labels["region"] = "eu"
+		stats := averager.GetStatsGroupedBy("region", "build")
+		Expect(stats).To(ContainSubstring(`{"action":"run","labels":{"region":"us"},"avg":150,"count":1,`))
+	})
+
+	It("should reject new label sets over the cardinality limit without changing any stats", func() {
+		expStats := averager.GetStats()
+		err := averager.AddAction(`{"action":"jump","time":10,"labels":{"region":"ap"}}`)
+		verifyValidationError(err, actionaverager.ErrCardinalityLimit, "labels")
+		Expect(err.Error()).To(Equal(`action "jump" already has the max 4 label sets, rejecting`))
+		Expect(averager.GetStats()).To(Equal(expStats))
+
+		// NOTE: existing label sets and other actions can still be added to
+		Expect(averager.AddAction(`{"action":"jump","time":10,"labels":{"region":"us","build":"1.1"}}`)).To(Succeed())
+		Expect(averager.AddSample("run", 5)).To(Succeed())
+
+		err = averager.AddActions(`[{"action":"jump","time":10,"labels":{"region":"ap"}},{"action":"run","time":"5"},{"action":"jump","time":10}]`)
+		var batchErr *actionaverager.BatchError
+		Expect(errors.As(err, &batchErr)).To(BeTrue())
+		Expect(batchErr.Errors).To(HaveLen(2))
+		Expect(batchErr.Errors[0].Index).To(Equal(0))
+		Expect(errors.Is(batchErr.Errors[0], actionaverager.ErrCardinalityLimit)).To(BeTrue())
+		Expect(batchErr.Errors[1].Index).To(Equal(1))
+		Expect(averager.GetRejections().Reasons).To(HaveKeyWithValue("cardinality_limit", uint64(2)))
+	})
+
+	It("should reject invalid labels", func() {
+		verifyValidationError(averager.AddAction(`{"action":"jump","time":10,"labels":["eu"]}`), actionaverager.ErrWrongType, "labels")
+		verifyValidationError(averager.AddAction(`{"action":"jump","time":10,"labels":{"region":1}}`), actionaverager.ErrWrongType, "labels")
+		verifyValidationError(averager.AddAction(`{"action":"jump","time":10,"labels":{"":"eu"}}`), actionaverager.ErrMissingField, "labels")
+		verifyValidationError(averager.AddAction(`{"action":"jump","time":10,"labels":{},"extra":1}`), actionaverager.ErrFieldCount, "")
+
+		labels := make(map[string]string)
+		for i := 0; i < 17; i++ {
+			labels[fmt.Sprint("label", i)] = "value"
+		}
+		verifyValidationError(averager.AddLabeledSample("jump", 10, labels), actionaverager.ErrCardinalityLimit, "labels")
+	})
+
+	It("should reject labels without labels enabled", func() {
+		averager = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		verifyValidationError(averager.AddAction(labeledActions[0]), actionaverager.ErrFieldCount, "")
+		Expect(averager.AddLabeledSample("jump", 10, map[string]string{"region": "eu"})).To(Succeed())
+		Expect(averager.GetStatsGroupedBy("region")).To(Equal(averager.GetStats()))
+	})
+
+	It("should keep label sets through snapshots and merges", func() {
+		var buf bytes.Buffer
+		Expect(averager.Snapshot(&buf)).To(Succeed())
+		restored := newOptionsAverager(actionaverager.WithLabels(maxLabelSets))
+		Expect(restored.Restore(bytes.NewReader(buf.Bytes()))).To(Succeed())
+		Expect(restored.GetStatsGroupedBy("region", "build")).To(Equal(averager.GetStatsGroupedBy("region", "build")))
+
+		// NOTE: actions merged without label sets are rolled up with the label sets without labels
+		unlabeled := actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		Expect(unlabeled.AddSample("jump", 90)).To(Succeed())
+		restored.Merge(unlabeled)
+		restored.Merge(averager)
+		stats := restored.GetStatsGroupedBy("region")
+		Expect(stats).To(HavePrefix(`[{"action":"jump","avg":76.66666666666667,"count":3,"sum":230,"min":70,"max":90,`))
+		Expect(stats).To(ContainSubstring(`{"action":"jump","labels":{"region":"eu"},"avg":20,"count":4,`))
+
+		// NOTE: an averager without labels enabled only keeps the roll up
+		unlabeled.Merge(averager)
+		Expect(unlabeled.GetStatsGroupedBy("region")).To(Equal(unlabeled.GetStats()))
+	})
+
+	It("should keep label sets when merging snapshots", func() {
+		other := newOptionsAverager(actionaverager.WithLabels(maxLabelSets))
+		Expect(other.AddLabeledSample("jump", 90, map[string]string{"region": "us"})).To(Succeed())
+		Expect(other.AddLabeledSample("jump", 20, map[string]string{"region": "ap"})).To(Succeed())
+		var buf0, buf1, merged bytes.Buffer
+		Expect(averager.Snapshot(&buf0)).To(Succeed())
+		Expect(other.Snapshot(&buf1)).To(Succeed())
+		Expect(actionaverager.MergeSnapshots(&merged, &buf0, &buf1)).To(Succeed())
+
+		// NOTE: the merged snapshot has more label sets than the limit of either averager, restoring does not
+		// enforce it
+		combined := newOptionsAverager(actionaverager.WithLabels(maxLabelSets))
+		Expect(combined.Restore(&merged)).To(Succeed())
+		averager.Merge(other)
+		Expect(combined.GetStatsGroupedBy("region")).To(Equal(averager.GetStatsGroupedBy("region")))
+		Expect(combined.GetStatsGroupedBy("region")).To(ContainSubstring(`{"action":"jump","labels":{"region":"us"},"avg":70,"count":2,`))
+	})
+
+	It("should fail to create an averager with an invalid label set limit", func() {
+		_, err := actionaverager.NewActionAveragerWithOptions(actionaverager.WithLabels(0))
+		Expect(err).To(HaveOccurred())
+	})
+})
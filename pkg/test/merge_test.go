@@ -0,0 +1,105 @@
+package actionaverager_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+var _ = Describe("action-averager merge tests", func() {
+	var averager0 *actionaverager.ActionAverage
+	var averager1 *actionaverager.ActionAverage
+	var combined *actionaverager.ActionAverage
+	BeforeEach(func() {
+		averager0 = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		averager1 = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		combined = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	})
+
+	It("should merge to the same stats as adding every action to one averager", func() {
+		addMultipleActions(averager0, []string{`{"action":"run","time":50}`, `{"action":"run","time":70}`}, !delay)
+		addMultipleActions(averager1, []string{`{"action":"run","time":30}`, `{"action":"jump","time":10}`}, !delay)
+		averager0.Merge(averager1)
+
+		stats := averager0.GetStats()
+		expStats := []string{
+			`{"action":"jump","avg":10,"count":1,"sum":10,"min":10,"max":10,"variance":0,"stddev":0}`,
+			`{"action":"run","avg":50,"count":3,"sum":150,"min":30,"max":70,"variance":266.6666666666667,"stddev":16.32993161855452}`,
+		}
+		verifyStats(stats, expStats)
+
+		// NOTE: merging must not change the averager being merged from
+		Expect(averager1.GetStats()).To(ContainSubstring(`{"action":"run","avg":30,"count":1,`))
+	})
+
+	It("should merge quantile sketches and running stats that can be continued", func() {
+		for i := 0; i < 100; i++ {
+			Expect(combined.AddSample("run", float64(i))).To(Succeed())
+			if i%2 == 0 {
+				Expect(averager0.AddSample("run", float64(i))).To(Succeed())
+			} else {
+				Expect(averager1.AddSample("run", float64(i))).To(Succeed())
+			}
+		}
+		averager0.Merge(averager1)
+
+		expStats, err := combined.GetStatsWithQuantiles(0.1, 0.5, 0.99)
+		Expect(err).NotTo(HaveOccurred())
+		stats, err := averager0.GetStatsWithQuantiles(0.1, 0.5, 0.99)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats).To(Equal(expStats))
+
+		Expect(averager1.AddSample("run", 1000)).To(Succeed())
+		Expect(averager0.GetStats()).To(ContainSubstring(`"count":100,`))
+	})
+
+	It("should merge snapshots from many averagers", func() {
+		Expect(averager0.AddSample("run", 50)).To(Succeed())
+		Expect(averager1.AddSample("run", 70)).To(Succeed())
+		var buf0, buf1, merged bytes.Buffer
+		Expect(averager0.Snapshot(&buf0)).To(Succeed())
+		Expect(averager1.Snapshot(&buf1)).To(Succeed())
+
+		Expect(actionaverager.MergeSnapshots(&merged, bytes.NewReader(buf0.Bytes()), bytes.NewReader(buf1.Bytes()))).To(Succeed())
+		Expect(combined.Restore(&merged)).To(Succeed())
+		expStats := `[{"action":"run","avg":60,"count":2,"sum":120,"min":50,"max":70,"variance":100,"stddev":10}]`
+		Expect(combined.GetStats()).To(Equal(expStats))
+
+		Expect(averager0.MergeSnapshot(&buf1)).To(Succeed())
+		Expect(averager0.GetStats()).To(Equal(expStats))
+	})
+
+	It("should double an averager merged into itself", func() {
+		Expect(averager0.AddSample("run", 50)).To(Succeed())
+		averager0.Merge(averager0)
+		Expect(averager0.GetStats()).To(Equal(`[{"action":"run","avg":50,"count":2,"sum":100,"min":50,"max":50,"variance":0,"stddev":0}]`))
+	})
+
+	It("should reject an invalid snapshot and leave the state unchanged", func() {
+		Expect(averager0.AddSample("run", 50)).To(Succeed())
+		expStats := averager0.GetStats()
+		Expect(averager0.MergeSnapshot(bytes.NewBufferString(`{"version":2,"actions":[]}`))).NotTo(Succeed())
+		Expect(averager0.GetStats()).To(Equal(expStats))
+
+		var merged bytes.Buffer
+		Expect(actionaverager.MergeSnapshots(&merged, bytes.NewBufferString("not json"))).NotTo(Succeed())
+		Expect(merged.Len()).To(Equal(0))
+	})
+
+	It("should merge concurrently without deadlocking", func() {
+		Expect(averager0.AddSample("run", 50)).To(Succeed())
+		Expect(averager1.AddSample("run", 70)).To(Succeed())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			averager0.Merge(averager1)
+		}()
+		averager1.Merge(averager0)
+		Eventually(done).Should(BeClosed())
+		Expect(averager0.GetStats()).To(ContainSubstring(`"max":70`))
+		Expect(averager1.GetStats()).To(ContainSubstring(`"min":50`))
+	})
+})
@@ -0,0 +1,106 @@
+package actionaverager_test
+
+import (
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+func newOptionsAverager(opts ...actionaverager.Option) *actionaverager.ActionAverage {
+	averager, err := actionaverager.NewActionAveragerWithOptions(opts...)
+	Expect(err).NotTo(HaveOccurred())
+	return averager.(*actionaverager.ActionAverage)
+}
+
+var _ = Describe("action-averager options tests", func() {
+	It("should be as strict as NewActionAverager without any options", func() {
+		averager := newOptionsAverager()
+		err := averager.AddAction(`{"action":"run","time":123,"host":"a"}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal(`unexpected number of fields, 3, in input {"action":"run","time":123,"host":"a"}, expect 2, rejecting`))
+		Expect(averager.AddAction(`{"action":"run","time":0}`)).To(Succeed())
+		Expect(averager.GetStats()).To(HavePrefix(`[{"action":"run","avg":0,"count":1`))
+	})
+
+	It("should ignore extra fields with lenient fields", func() {
+		averager := newOptionsAverager(actionaverager.WithLenientFields())
+		actions := []string{
+			`{"action":"run","time":50,"ts":1600000000}`,
+			`{"host":"a","action":"run","time":70}`,
+		}
+		addMultipleActions(averager, actions, !delay)
+		Expect(averager.GetStats()).To(Equal(`[{"action":"run","avg":60,"count":2,"sum":120,"min":50,"max":70,"variance":100,"stddev":10}]`))
+
+		verifyValidationError(averager.AddAction(`{"action":"run","ts":1600000000}`), actionaverager.ErrMissingField, "time")
+		verifyValidationError(averager.AddAction(`{"action":"run","time":-1,"ts":1600000000}`), actionaverager.ErrNegativeTime, "time")
+	})
+
+	It("should reject times outside of the time range", func() {
+		averager := newOptionsAverager(actionaverager.WithTimeRange(1, 100))
+		Expect(averager.AddAction(`{"action":"run","time":1}`)).To(Succeed())
+		Expect(averager.AddSample("run", 100)).To(Succeed())
+
+		err := averager.AddAction(`{"action":"run","time":0.5}`)
+		verifyValidationError(err, actionaverager.ErrTimeOutOfRange, "time")
+		Expect(err.Error()).To(Equal(`time value 0.5 for action "run" is not between 1 and 100, rejecting`))
+		verifyValidationError(averager.AddSample("run", 100.5), actionaverager.ErrTimeOutOfRange, "time")
+		Expect(averager.GetStats()).To(HavePrefix(`[{"action":"run","avg":50.5,"count":2`))
+	})
+
+	It("should reject actions that are too long", func() {
+		averager := newOptionsAverager(actionaverager.WithMaxActionLength(4))
+		Expect(averager.AddAction(`{"action":"jump","time":10}`)).To(Succeed())
+		err := averager.AddAction(`{"action":"jumps","time":10}`)
+		verifyValidationError(err, actionaverager.ErrActionTooLong, "action")
+		Expect(err.Error()).To(Equal(`action "jumps" is longer than the max length 4, rejecting`))
+	})
+
+	It("should only accept allowed actions", func() {
+		averager := newOptionsAverager(actionaverager.WithAllowedActions("run", "jump"))
+		Expect(averager.AddAction(`{"action":"jump","time":10}`)).To(Succeed())
+		verifyValidationError(averager.AddAction(`{"action":"skip","time":10}`), actionaverager.ErrActionNotAllowed, "action")
+		verifyValidationError(averager.AddSample("skip", 10), actionaverager.ErrActionNotAllowed, "action")
+		Expect(averager.GetStats()).To(HavePrefix(`[{"action":"jump",`))
+	})
+
+	It("should only accept actions that match the action pattern", func() {
+		averager := newOptionsAverager(actionaverager.WithActionPattern(regexp.MustCompile(`^[a-z]+(\.[a-z]+)*$`)))
+		Expect(averager.AddAction(`{"action":"http.get","time":10}`)).To(Succeed())
+		err := averager.AddAction(`{"action":"HTTP GET","time":10}`)
+		verifyValidationError(err, actionaverager.ErrActionNotAllowed, "action")
+		Expect(err.Error()).To(Equal(`action "HTTP GET" does not match the action pattern ^[a-z]+(\.[a-z]+)*$, rejecting`))
+	})
+
+	It("should ignore zero times without counting them", func() {
+		averager := newOptionsAverager(actionaverager.WithZeroTimePolicy(actionaverager.ZeroTimeIgnore), actionaverager.WithTimeRange(5, 100))
+		Expect(averager.AddAction(`{"action":"run","time":0}`)).To(Succeed())
+		Expect(averager.AddSample("run", 0)).To(Succeed())
+		Expect(averager.AddActions(`[{"action":"run","time":0},{"action":"run","time":10}]`)).To(Succeed())
+		Expect(averager.GetStats()).To(HavePrefix(`[{"action":"run","avg":10,"count":1,`))
+	})
+
+	It("should reject zero times", func() {
+		averager := newOptionsAverager(actionaverager.WithZeroTimePolicy(actionaverager.ZeroTimeReject))
+		verifyValidationError(averager.AddAction(`{"action":"run","time":0}`), actionaverager.ErrZeroTime, "time")
+		verifyValidationError(averager.AddDuration("run", 0), actionaverager.ErrZeroTime, "time")
+		Expect(averager.GetStats()).To(Equal(emptyStats))
+	})
+
+	It("should fail to create an averager with invalid options", func() {
+		invalidOpts := []actionaverager.Option{
+			actionaverager.WithTimeRange(-1, 10),
+			actionaverager.WithTimeRange(10, 1),
+			actionaverager.WithMaxActionLength(0),
+			actionaverager.WithActionPattern(nil),
+			actionaverager.WithAllowedActions(),
+			actionaverager.WithZeroTimePolicy(actionaverager.ZeroTimePolicy(-1)),
+		}
+		for _, opt := range invalidOpts {
+			_, err := actionaverager.NewActionAveragerWithOptions(opt)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+})
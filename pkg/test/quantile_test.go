@@ -0,0 +1,118 @@
+package actionaverager_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const (
+	quantileSeed        = 42
+	quantileNumSamples  = 10000
+	quantileRelAccuracy = 0.01
+)
+
+type quantileStats struct {
+	Action    string             `json:"action"`
+	Quantiles map[string]float64 `json:"quantiles"`
+}
+
+func getQuantileStats(averager *actionaverager.ActionAverage, quantiles ...float64) []quantileStats {
+	stats, err := averager.GetStatsWithQuantiles(quantiles...)
+	Expect(err).NotTo(HaveOccurred())
+	var output []quantileStats
+	Expect(json.Unmarshal([]byte(stats), &output)).To(Succeed())
+	return output
+}
+
+// exactQuantile uses the same definition of a quantile as GetStatsWithQuantiles documents
+func exactQuantile(sorted []float64, quantile float64) float64 {
+	return sorted[int(quantile*float64(len(sorted)-1))]
+}
+
+var _ = Describe("action-averager quantile tests", func() {
+	var averager *actionaverager.ActionAverage
+	BeforeEach(func() {
+		averager = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	})
+
+	It("should estimate quantiles within the documented error of the exact sorted sample quantiles", func() {
+		rng := rand.New(rand.NewSource(quantileSeed))
+		samples := make([]float64, quantileNumSamples)
+		for i := range samples {
+			// NOTE: a long tailed distribution with some zero times, like real latencies
+			if i%100 == 0 {
+				samples[i] = 0
+			} else {
+				samples[i] = rng.ExpFloat64() * 100
+			}
+			err := averager.AddAction(fmt.Sprintf(`{"action":"jump","time":%v}`, samples[i]))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		sort.Float64s(samples)
+
+		quantiles := []float64{0, 0.005, 0.1, 0.5, 0.9, 0.99, 0.999, 1}
+		output := getQuantileStats(averager, quantiles...)
+		Expect(output).To(HaveLen(1))
+		Expect(output[0].Action).To(Equal("jump"))
+		Expect(output[0].Quantiles).To(HaveLen(len(quantiles)))
+		for _, quantile := range quantiles {
+			exact := exactQuantile(samples, quantile)
+			estimate, ok := output[0].Quantiles[fmt.Sprint(quantile)]
+			Expect(ok).To(BeTrue())
+			Expect(estimate).To(BeNumerically("~", exact, exact*quantileRelAccuracy), "quantile %v", quantile)
+		}
+	})
+
+	It("should estimate quantiles for each action separately", func() {
+		actions := []string{
+			`{"action":"run","time":10}`,
+			`{"action":"run","time":20}`,
+			`{"action":"run","time":30}`,
+			`{"action":"skip","time":1000}`,
+		}
+		addMultipleActions(averager, actions, !delay)
+		output := getQuantileStats(averager, 0.5)
+		Expect(output).To(HaveLen(2))
+		for _, item := range output {
+			switch item.Action {
+			case "run":
+				Expect(item.Quantiles["0.5"]).To(BeNumerically("~", 20, 20*quantileRelAccuracy))
+			case "skip":
+				Expect(item.Quantiles["0.5"]).To(Equal(float64(1000)))
+			default:
+				Fail("unexpected action " + item.Action)
+			}
+		}
+	})
+
+	It("should not include quantiles when none are requested", func() {
+		err := averager.AddAction(`{"action":"run","time":20}`)
+		Expect(err).NotTo(HaveOccurred())
+		stats, err := averager.GetStatsWithQuantiles()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats).To(Equal(averager.GetStats()))
+	})
+
+	It("should return an empty json array without any actions", func() {
+		stats, err := averager.GetStatsWithQuantiles(0.5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats).To(Equal(emptyStats))
+	})
+
+	It("should fail if a quantile is not between 0 and 1", func() {
+		err := averager.AddAction(`{"action":"run","time":20}`)
+		Expect(err).NotTo(HaveOccurred())
+		for _, quantile := range []float64{-0.1, 1.1, math.NaN()} {
+			_, err := averager.GetStatsWithQuantiles(0.5, quantile)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+})
@@ -0,0 +1,127 @@
+package actionaverager_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+var _ = Describe("action-averager rejection tests", func() {
+	var clock *fakeClock
+	BeforeEach(func() {
+		clock = newFakeClock()
+	})
+
+	It("should count rejections by reason separately from the stats", func() {
+		averager := actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		Expect(averager.GetRejectionStats()).To(Equal(`{"total":0,"reasons":{}}`))
+
+		Expect(averager.AddAction(`{"action":"run","time":50}`)).To(Succeed())
+		Expect(averager.AddAction(`{"action":"run","tome":50}`)).NotTo(Succeed())
+		Expect(averager.AddAction(`{"actoin":"run","time":50}`)).NotTo(Succeed())
+		Expect(averager.AddSample("run", -1)).NotTo(Succeed())
+		Expect(averager.AddActions(`[{"action":"run","time":"50"},{"action":"run","time":70}]`)).NotTo(Succeed())
+		Expect(averager.AddActions(`not json`)).NotTo(Succeed())
+
+		Expect(averager.GetRejectionStats()).To(Equal(`{"total":5,"reasons":{"missing_field":2,"negative_time":1,"other":1,"wrong_type":1}}`))
+		rejections := averager.GetRejections()
+		Expect(rejections.Total).To(Equal(uint64(5)))
+		Expect(rejections.Reasons).To(HaveKeyWithValue("missing_field", uint64(2)))
+		Expect(averager.GetStats()).To(HavePrefix(`[{"action":"run","avg":60,"count":2,`))
+	})
+
+	It("should call a dead letter callback with every rejected payload", func() {
+		var deadLetters []*actionaverager.DeadLetter
+		averager, err := actionaverager.NewActionAveragerWithOptions(
+			actionaverager.WithDeadLetterSink(actionaverager.DeadLetterFunc(func(deadLetter *actionaverager.DeadLetter) {
+				deadLetters = append(deadLetters, deadLetter)
+			})),
+			actionaverager.WithClock(clock.Now),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(averager.AddAction(`{"action":"run","time":-1}`)).NotTo(Succeed())
+		clock.Advance(time.Second)
+		Expect(actionaverager.AddSample(averager, "run", -2)).NotTo(Succeed())
+
+		Expect(deadLetters).To(HaveLen(2))
+		Expect(*deadLetters[0]).To(Equal(actionaverager.DeadLetter{
+			Time:   newFakeClock().Now(),
+			Input:  `{"action":"run","time":-1}`,
+			Reason: "negative_time",
+			Error:  `negative time value for input {"action":"run","time":-1}, rejecting`,
+		}))
+		Expect(deadLetters[1].Time).To(Equal(clock.Now()))
+		Expect(deadLetters[1].Input).To(BeEmpty())
+		Expect(deadLetters[1].Error).To(Equal(`negative time value -2 for action run, rejecting`))
+	})
+
+	It("should send dead letters on a channel without blocking", func() {
+		deadCh := make(chan *actionaverager.DeadLetter, 1)
+		sink := actionaverager.NewChannelDeadLetterSink(deadCh)
+		averager, err := actionaverager.NewActionAveragerWithOptions(actionaverager.WithDeadLetterSink(sink))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(averager.AddAction(`"run"`)).NotTo(Succeed())
+		Expect(averager.AddAction(`[]`)).NotTo(Succeed())
+		var deadLetter *actionaverager.DeadLetter
+		Expect(deadCh).To(Receive(&deadLetter))
+		Expect(deadLetter.Input).To(Equal(`"run"`))
+		Expect(deadLetter.Reason).To(Equal("not_object"))
+		Expect(sink.Dropped()).To(Equal(uint64(1)))
+	})
+
+	It("should append dead letters to a file as newline delimited json", func() {
+		dir, err := ioutil.TempDir("", "deadletter")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "dead.ndjson")
+
+		sink, err := actionaverager.NewFileDeadLetterSink(path)
+		Expect(err).NotTo(HaveOccurred())
+		averager, err := actionaverager.NewActionAveragerWithOptions(actionaverager.WithDeadLetterSink(sink), actionaverager.WithClock(clock.Now))
+		Expect(err).NotTo(HaveOccurred())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(averager.AddAction(`{"action":"run","time":50,"extra":1}`)).NotTo(Succeed())
+			}()
+		}
+		wg.Wait()
+		Expect(sink.Close()).To(Succeed())
+
+		file, err := os.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		numLines := 0
+		for scanner.Scan() {
+			var deadLetter actionaverager.DeadLetter
+			Expect(json.Unmarshal(scanner.Bytes(), &deadLetter)).To(Succeed())
+			Expect(deadLetter.Input).To(Equal(`{"action":"run","time":50,"extra":1}`))
+			Expect(deadLetter.Reason).To(Equal("field_count"))
+			Expect(deadLetter.Time.Equal(clock.Now())).To(BeTrue())
+			numLines++
+		}
+		Expect(scanner.Err()).NotTo(HaveOccurred())
+		Expect(numLines).To(Equal(10))
+	})
+
+	It("should fail to create an averager with a nil dead letter sink", func() {
+		_, err := actionaverager.NewActionAveragerWithOptions(actionaverager.WithDeadLetterSink(nil))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,99 @@
+package actionaverager_test
+
+import (
+	"math"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+var _ = Describe("action-averager sample tests", func() {
+	var averager *actionaverager.ActionAverage
+	BeforeEach(func() {
+		averager = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	})
+
+	It("should give the same stats for samples and json actions", func() {
+		jsonAverager := actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		actions := []string{
+			`{"action":"run","time":55.5}`,
+			`{"action":"run","time":0}`,
+			`{"action":"run","time":145.37}`,
+		}
+		addMultipleActions(jsonAverager, actions, !delay)
+
+		Expect(averager.AddSample("run", 55.5)).To(Succeed())
+		Expect(averager.AddSample("run", 0)).To(Succeed())
+		Expect(averager.AddSample("run", 145.37)).To(Succeed())
+		Expect(averager.GetStats()).To(Equal(jsonAverager.GetStats()))
+
+		stats, err := averager.GetStatsWithQuantiles(0.5, 0.99)
+		Expect(err).NotTo(HaveOccurred())
+		expStats, err := jsonAverager.GetStatsWithQuantiles(0.5, 0.99)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats).To(Equal(expStats))
+	})
+
+	It("should add durations as milliseconds", func() {
+		Expect(averager.AddDuration("run", 1500*time.Microsecond)).To(Succeed())
+		Expect(averager.AddDuration("run", 2*time.Second)).To(Succeed())
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(`[{"action":"run","avg":1000.75,"count":2,"sum":2001.5,"min":1.5,"max":2000,"variance":998500.5625,"stddev":999.25}]`))
+	})
+
+	It("should reject negative and non finite times", func() {
+		verifyValidationError(averager.AddSample("run", -1), actionaverager.ErrNegativeTime, "time")
+		for _, timeFlt := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+			verifyValidationError(averager.AddSample("run", timeFlt), actionaverager.ErrNonFiniteTime, "time")
+		}
+		verifyValidationError(averager.AddDuration("run", -time.Millisecond), actionaverager.ErrNegativeTime, "time")
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(emptyStats))
+	})
+
+	It("should give the same stats for a weighted sample as for the sample added weight times", func() {
+		repeatedAverager := actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		for i := 0; i < 4; i++ {
+			Expect(repeatedAverager.AddSample("run", 30)).To(Succeed())
+		}
+		Expect(repeatedAverager.AddSample("run", 10)).To(Succeed())
+
+		Expect(averager.AddWeightedSample("run", 10, 1)).To(Succeed())
+		Expect(averager.AddWeightedSample("run", 30, 4)).To(Succeed())
+		Expect(averager.GetStats()).To(Equal(repeatedAverager.GetStats()))
+
+		stats, err := averager.GetStatsWithQuantiles(0.1, 0.5)
+		Expect(err).NotTo(HaveOccurred())
+		expStats, err := repeatedAverager.GetStatsWithQuantiles(0.1, 0.5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats).To(Equal(expStats))
+	})
+
+	It("should reject weighted samples with an invalid time or weight", func() {
+		verifyValidationError(averager.AddWeightedSample("run", -1, 2), actionaverager.ErrNegativeTime, "time")
+		for _, weight := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+			Expect(averager.AddWeightedSample("run", 20, weight)).NotTo(Succeed())
+		}
+		Expect(averager.GetStats()).To(Equal(emptyStats))
+	})
+
+	It("should accept samples in every averager", func() {
+		clock := newFakeClock()
+		windowAverager, err := actionaverager.NewWindowActionAverager(window, numBuckets, clock.Now)
+		Expect(err).NotTo(HaveOccurred())
+		ewmaAverager, err := actionaverager.NewEWMAActionAverager(halfLife, clock.Now)
+		Expect(err).NotTo(HaveOccurred())
+
+		averagers := []actionaverager.ActionAverager{averager, windowAverager, ewmaAverager}
+		for _, av := range averagers {
+			sampleAverager, ok := av.(actionaverager.SampleAverager)
+			Expect(ok).To(BeTrue())
+			Expect(sampleAverager.AddSample("run", 20)).To(Succeed())
+			Expect(sampleAverager.AddSample("run", -20)).NotTo(Succeed())
+			Expect(sampleAverager.GetStats()).To(HavePrefix(`[{"action":"run","avg":20,"count":1`))
+		}
+	})
+})
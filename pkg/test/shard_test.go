@@ -0,0 +1,73 @@
+package actionaverager_test
+
+import (
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const (
+	shardNumActions = 200
+	shardNumAdds    = 50
+)
+
+var _ = Describe("action-averager shard tests", func() {
+	var averager *actionaverager.ActionAverage
+	BeforeEach(func() {
+		averager = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	})
+
+	It("should keep every action consistent when many actions are added concurrently", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < shardNumActions; i++ {
+			wg.Add(1)
+			go func(actStr string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for j := 1; j <= shardNumAdds; j++ {
+					Expect(averager.AddSample(actStr, float64(j))).To(Succeed())
+				}
+			}(fmt.Sprintf("action%03d", i))
+		}
+		wg.Wait()
+
+		statsList := averager.GetStatsList()
+		Expect(statsList).To(HaveLen(shardNumActions))
+		for i, stats := range statsList {
+			Expect(stats.Action).To(Equal(fmt.Sprintf("action%03d", i)))
+			Expect(stats.Count).To(Equal(float64(shardNumAdds)))
+			Expect(stats.Sum).To(Equal(float64(shardNumAdds * (shardNumAdds + 1) / 2)))
+			Expect(stats.Min).To(Equal(float64(1)))
+			Expect(stats.Max).To(Equal(float64(shardNumAdds)))
+		}
+	})
+
+	It("should never show a partially added action while stats are read concurrently", func() {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			for i := 0; i < shardNumActions; i++ {
+				Expect(averager.AddActions(fmt.Sprintf(`[{"action":"action%03d","time":10},{"action":"action%03d","time":30}]`, i, i))).To(Succeed())
+			}
+		}()
+
+		for finished := false; !finished; {
+			select {
+			case <-done:
+				finished = true
+			default:
+			}
+			for _, stats := range averager.GetStatsList() {
+				// NOTE: both times of an action are added under the same shard lock, so they are seen together
+				Expect(stats.Count).To(Equal(float64(2)))
+				Expect(stats.Average).To(Equal(float64(20)))
+			}
+		}
+		Expect(averager.GetStatsList()).To(HaveLen(shardNumActions))
+	})
+})
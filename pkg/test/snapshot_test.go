@@ -0,0 +1,86 @@
+package actionaverager_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+var _ = Describe("action-averager snapshot tests", func() {
+	var averager *actionaverager.ActionAverage
+	var restored *actionaverager.ActionAverage
+	BeforeEach(func() {
+		averager = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		restored = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		for i := 0; i < 100; i++ {
+			Expect(averager.AddSample("run", float64(i)*1.1)).To(Succeed())
+			Expect(averager.AddSample("jump", float64(i%7)/3)).To(Succeed())
+		}
+	})
+
+	It("should restore the exact same stats", func() {
+		var buf bytes.Buffer
+		Expect(averager.Snapshot(&buf)).To(Succeed())
+		Expect(restored.Restore(&buf)).To(Succeed())
+
+		Expect(restored.GetStats()).To(Equal(averager.GetStats()))
+		expStats, err := averager.GetStatsWithQuantiles(0.1, 0.5, 0.99)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored.GetStatsWithQuantiles(0.1, 0.5, 0.99)).To(Equal(expStats))
+	})
+
+	It("should continue the same running stats after restoring", func() {
+		var buf bytes.Buffer
+		Expect(averager.Snapshot(&buf)).To(Succeed())
+		Expect(restored.Restore(&buf)).To(Succeed())
+
+		for _, av := range []*actionaverager.ActionAverage{averager, restored} {
+			Expect(av.AddSample("run", 1000.01)).To(Succeed())
+			Expect(av.AddSample("skip", 3)).To(Succeed())
+		}
+		Expect(restored.GetStats()).To(Equal(averager.GetStats()))
+	})
+
+	It("should write the same snapshot for the same state", func() {
+		var buf0, buf1 bytes.Buffer
+		Expect(averager.Snapshot(&buf0)).To(Succeed())
+		Expect(restored.Restore(bytes.NewReader(buf0.Bytes()))).To(Succeed())
+		Expect(restored.Snapshot(&buf1)).To(Succeed())
+		Expect(buf1.String()).To(Equal(buf0.String()))
+	})
+
+	It("should replace any existing state", func() {
+		Expect(restored.AddSample("walk", 10)).To(Succeed())
+		var buf bytes.Buffer
+		Expect(averager.Snapshot(&buf)).To(Succeed())
+		Expect(restored.Restore(&buf)).To(Succeed())
+		_, ok := restored.GetActionStats("walk")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should reject invalid snapshots and leave the state unchanged", func() {
+		Expect(restored.AddSample("walk", 10)).To(Succeed())
+		expStats := restored.GetStats()
+
+		var buf bytes.Buffer
+		Expect(averager.Snapshot(&buf)).To(Succeed())
+		snapshots := []string{
+			"",
+			"not json",
+			buf.String()[:buf.Len()/2],
+			strings.Replace(buf.String(), `"version":1`, `"version":2`, 1),
+			strings.Replace(buf.String(), `"call_count":100`, `"call_count":-1`, 1),
+			`{"version":1,"actions":[null]}`,
+			fmt.Sprintf(`{"version":1,"actions":[%s,%s]}`, `{"action":"run","call_count":1,"sketch":{"count":1}}`, `{"action":"run","call_count":1,"sketch":{"count":1}}`),
+		}
+		for _, snapshot := range snapshots {
+			Expect(restored.Restore(strings.NewReader(snapshot))).NotTo(Succeed(), snapshot)
+			Expect(restored.GetStats()).To(Equal(expStats))
+		}
+	})
+})
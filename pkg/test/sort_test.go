@@ -0,0 +1,66 @@
+package actionaverager_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const (
+	sortedBike = `{"action":"bike","avg":30,"count":1,"sum":30,"min":30,"max":30,"variance":0,"stddev":0}`
+	sortedHop  = `{"action":"hop","avg":10,"count":2,"sum":20,"min":5,"max":15,"variance":25,"stddev":5}`
+	sortedRun  = `{"action":"run","avg":30,"count":3,"sum":90,"min":20,"max":40,"variance":66.66666666666667,"stddev":8.16496580927726}`
+	sortedSkip = `{"action":"skip","avg":50,"count":1,"sum":50,"min":50,"max":50,"variance":0,"stddev":0}`
+)
+
+var _ = Describe("action-averager sort tests", func() {
+	var averager *actionaverager.ActionAverage
+	BeforeEach(func() {
+		averager = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+		actions := []string{
+			`{"action":"skip","time":50}`,
+			`{"action":"run","time":20}`,
+			`{"action":"hop","time":5}`,
+			`{"action":"run","time":30}`,
+			`{"action":"bike","time":30}`,
+			`{"action":"hop","time":15}`,
+			`{"action":"run","time":40}`,
+		}
+		addMultipleActions(averager, actions, !delay)
+	})
+
+	It("should order GetStats by action name", func() {
+		verifyStats(averager.GetStats(), []string{sortedBike, sortedHop, sortedRun, sortedSkip})
+		Expect(averager.GetStatsSorted(actionaverager.SortByAction, actionaverager.Ascending)).To(Equal(averager.GetStats()))
+	})
+
+	It("should order stats by action name descending", func() {
+		stats := averager.GetStatsSorted(actionaverager.SortByAction, actionaverager.Descending)
+		verifyStats(stats, []string{sortedSkip, sortedRun, sortedHop, sortedBike})
+	})
+
+	It("should order stats by average and break ties by action name", func() {
+		stats := averager.GetStatsSorted(actionaverager.SortByAverage, actionaverager.Ascending)
+		verifyStats(stats, []string{sortedHop, sortedBike, sortedRun, sortedSkip})
+
+		stats = averager.GetStatsSorted(actionaverager.SortByAverage, actionaverager.Descending)
+		verifyStats(stats, []string{sortedSkip, sortedBike, sortedRun, sortedHop})
+	})
+
+	It("should order stats by count and break ties by action name", func() {
+		stats := averager.GetStatsSorted(actionaverager.SortByCount, actionaverager.Ascending)
+		verifyStats(stats, []string{sortedBike, sortedSkip, sortedHop, sortedRun})
+
+		stats = averager.GetStatsSorted(actionaverager.SortByCount, actionaverager.Descending)
+		verifyStats(stats, []string{sortedRun, sortedHop, sortedBike, sortedSkip})
+	})
+
+	It("should order GetStatsList the same as GetStats", func() {
+		statsList := averager.GetStatsList()
+		Expect(statsList).To(HaveLen(4))
+		for i, action := range []string{"bike", "hop", "run", "skip"} {
+			Expect(statsList[i].Action).To(Equal(action))
+		}
+	})
+})
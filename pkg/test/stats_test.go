@@ -0,0 +1,76 @@
+package actionaverager_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+var _ = Describe("action-averager typed stats tests", func() {
+	var averager *actionaverager.ActionAverage
+	BeforeEach(func() {
+		averager = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	})
+
+	It("should return the same stats as GetStats", func() {
+		actions := []string{
+			`{"action":"run","time":10}`,
+			`{"action":"jump","time":20}`,
+			`{"action":"run","time":30}`,
+		}
+		addMultipleActions(averager, actions, !delay)
+
+		var expStats []actionaverager.ActionStats
+		Expect(json.Unmarshal([]byte(averager.GetStats()), &expStats)).To(Succeed())
+		Expect(averager.GetStatsList()).To(ConsistOf(expStats))
+	})
+
+	It("should return the stats for a single action", func() {
+		actions := []string{
+			`{"action":"run","time":10}`,
+			`{"action":"jump","time":20}`,
+			`{"action":"run","time":30}`,
+		}
+		addMultipleActions(averager, actions, !delay)
+
+		stats, ok := averager.GetActionStats("run")
+		Expect(ok).To(BeTrue())
+		Expect(stats).To(Equal(actionaverager.ActionStats{
+			Action:   "run",
+			Average:  20,
+			Count:    2,
+			Sum:      40,
+			Min:      10,
+			Max:      30,
+			Variance: 100,
+			StdDev:   10,
+		}))
+	})
+
+	It("should not return stats for an action that has not been added", func() {
+		Expect(averager.GetStatsList()).To(BeEmpty())
+		_, ok := averager.GetActionStats("run")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should return a copy that does not change when actions are added", func() {
+		err := averager.AddAction(`{"action":"run","time":10}`)
+		Expect(err).NotTo(HaveOccurred())
+		statsList := averager.GetStatsList()
+		stats, ok := averager.GetActionStats("run")
+		Expect(ok).To(BeTrue())
+
+		err = averager.AddAction(`{"action":"run","time":30}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(statsList).To(HaveLen(1))
+		Expect(statsList[0].Count).To(Equal(float64(1)))
+		Expect(stats.Average).To(Equal(float64(10)))
+
+		stats, ok = averager.GetActionStats("run")
+		Expect(ok).To(BeTrue())
+		Expect(stats.Average).To(Equal(float64(20)))
+	})
+})
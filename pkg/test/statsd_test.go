@@ -0,0 +1,143 @@
+package actionaverager_test
+
+import (
+	"net"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+	"github.com/action-averager/pkg/statsd"
+)
+
+const (
+	loopbackAddr = "127.0.0.1:0"
+
+	statsdPacket = "jump:111|ms\nrun:50|ms|@0.5\n\njump:89|h|#region:us\nrun:70:90|ms\nhits:1|c"
+)
+
+var _ = Describe("action-averager statsd tests", func() {
+	Context("parsing lines", func() {
+		It("should parse timers, histograms, sample rates and multiple values", func() {
+			metrics, err := statsd.ParseLine("jump:111|ms")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metrics).To(Equal([]statsd.Metric{{Action: "jump", Time: 111, SampleRate: 1}}))
+
+			metrics, err = statsd.ParseLine("jump:11.5|h|@0.25|#region:us")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metrics).To(Equal([]statsd.Metric{{Action: "jump", Time: 11.5, SampleRate: 0.25}}))
+
+			metrics, err = statsd.ParseLine("jump:1:2|ms")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metrics).To(Equal([]statsd.Metric{
+				{Action: "jump", Time: 1, SampleRate: 1},
+				{Action: "jump", Time: 2, SampleRate: 1},
+			}))
+		})
+
+		It("should reject invalid lines", func() {
+			lines := []string{
+				"jump",
+				"jump:111",
+				":111|ms",
+				"jump|ms",
+				"jump:fast|ms",
+				"jump:111|c",
+				"jump:111|ms|@0",
+				"jump:111|ms|@2",
+				"jump:111|ms|extra",
+			}
+			for _, line := range lines {
+				_, err := statsd.ParseLine(line)
+				Expect(err).To(HaveOccurred(), line)
+			}
+		})
+	})
+
+	Context("listening on loopback", func() {
+		var averager actionaverager.ActionAverager
+		var server *statsd.Server
+		var errsMux sync.Mutex
+		var errs []error
+		BeforeEach(func() {
+			averager = actionaverager.NewActionAverager()
+			errs = nil
+			server = statsd.NewServer(averager, func(err error) {
+				errsMux.Lock()
+				defer errsMux.Unlock()
+				errs = append(errs, err)
+			})
+		})
+		numErrs := func() int {
+			errsMux.Lock()
+			defer errsMux.Unlock()
+			return len(errs)
+		}
+		// NOTE: "run:50|ms|@0.5" is added with a weight of 2, as if 50 had been received twice
+		expStats := []string{
+			`{"action":"jump","avg":100,"count":2,"sum":200,"min":89,"max":111,"variance":121,"stddev":11}`,
+			`{"action":"run","avg":65,"count":4,"sum":260,"min":50,"max":90,"variance":275,"stddev":16.583123951777}`,
+		}
+
+		It("should add timings from UDP packets", func() {
+			conn, err := net.ListenPacket("udp", loopbackAddr)
+			Expect(err).NotTo(HaveOccurred())
+			done := make(chan error)
+			go func() {
+				done <- server.ServeUDP(conn)
+			}()
+
+			client, err := net.Dial("udp", conn.LocalAddr().String())
+			Expect(err).NotTo(HaveOccurred())
+			defer client.Close()
+			_, err = client.Write([]byte(statsdPacket))
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(averager.GetStats).Should(Equal("[" + expStats[0] + "," + expStats[1] + "]"))
+			Eventually(numErrs).Should(Equal(1))
+
+			Expect(conn.Close()).To(Succeed())
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("should add timings from TCP connections", func() {
+			listener, err := net.Listen("tcp", loopbackAddr)
+			Expect(err).NotTo(HaveOccurred())
+			done := make(chan error)
+			go func() {
+				done <- server.ServeTCP(listener)
+			}()
+
+			client, err := net.Dial("tcp", listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			_, err = client.Write([]byte(statsdPacket + "\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.Close()).To(Succeed())
+
+			Eventually(averager.GetStats).Should(Equal("[" + expStats[0] + "," + expStats[1] + "]"))
+			Eventually(numErrs).Should(Equal(1))
+
+			Expect(listener.Close()).To(Succeed())
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("should scale the count and sum of sampled timings by the sample rate", func() {
+			server.HandlePacket("jump:111|ms|@0.1\njump:89|ms")
+			Expect(numErrs()).To(Equal(0))
+			verifyStats(averager.GetStats(), []string{
+				`{"action":"jump","avg":109,"count":11,"sum":1199,"min":89,"max":111,"variance":40,"stddev":6.324555320336759}`,
+			})
+		})
+
+		It("should add timings to averagers without AddSample or AddWeightedSample", func() {
+			jsonOnly := struct{ actionaverager.ActionAverager }{averager}
+			jsonServer := statsd.NewServer(jsonOnly, nil)
+			jsonServer.HandlePacket(statsdPacket)
+			verifyStats(averager.GetStats(), []string{
+				expStats[0],
+				`{"action":"run","avg":70,"count":3,"sum":210,"min":50,"max":90,"variance":266.6666666666667,"stddev":16.32993161855452}`,
+			})
+		})
+	})
+})
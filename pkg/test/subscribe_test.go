@@ -0,0 +1,73 @@
+package actionaverager_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const subscribeInterval = 10 * time.Millisecond
+
+var _ = Describe("action-averager subscribe tests", func() {
+	var averager *actionaverager.ActionAverage
+	BeforeEach(func() {
+		averager = actionaverager.NewActionAverager().(*actionaverager.ActionAverage)
+	})
+
+	It("should send the current stats and then the stats whenever they change", func() {
+		statsCh, unsubscribe := averager.Subscribe(subscribeInterval)
+		defer unsubscribe()
+		Eventually(statsCh).Should(Receive(Equal(emptyStats)))
+
+		err := averager.AddAction(`{"action":"run","time":20}`)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(statsCh).Should(Receive(Equal(averager.GetStats())))
+		Consistently(statsCh, 5*subscribeInterval, subscribeInterval).ShouldNot(Receive())
+	})
+
+	It("should coalesce changes and only keep the latest stats for a slow subscriber", func() {
+		statsCh, unsubscribe := averager.Subscribe(subscribeInterval)
+		defer unsubscribe()
+
+		for i := 0; i < 100; i++ {
+			err := averager.AddAction(fmt.Sprintf(`{"action":"run","time":%d}`, i))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		// NOTE: the subscriber has not read anything yet, wait until the latest stats have replaced the first stats
+		latestStats := averager.GetStats()
+		var stats string
+		Eventually(func() string {
+			select {
+			case stats = <-statsCh:
+			default:
+			}
+			return stats
+		}).Should(Equal(latestStats))
+	})
+
+	It("should close the channel when unsubscribed", func() {
+		statsCh, unsubscribe := averager.Subscribe(subscribeInterval)
+		unsubscribe()
+		unsubscribe()
+		Eventually(statsCh).Should(BeClosed())
+	})
+
+	It("should not block AddAction while nothing is reading stats", func() {
+		_, unsubscribe := averager.Subscribe(subscribeInterval)
+		defer unsubscribe()
+
+		done := make(chan bool)
+		go func() {
+			defer GinkgoRecover()
+			for i := 0; i < 1000; i++ {
+				Expect(averager.AddSample("run", float64(i))).To(Succeed())
+			}
+			done <- true
+		}()
+		Eventually(done).Should(Receive())
+	})
+})
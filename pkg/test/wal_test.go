@@ -0,0 +1,276 @@
+package actionaverager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+	"github.com/action-averager/pkg/wal"
+)
+
+const walSyncInterval = 10 * time.Millisecond
+
+var walActions = []string{
+	`{"action":"run","time":50}`,
+	`{"action":"jump","time":10}`,
+	`{"action":"run","time":70}`,
+}
+
+var walExpStats = []string{
+	`{"action":"jump","avg":10,"count":1,"sum":10,"min":10,"max":10,"variance":0,"stddev":0}`,
+	`{"action":"run","avg":60,"count":2,"sum":120,"min":50,"max":70,"variance":100,"stddev":10}`,
+}
+
+func segmentPaths(dir string) []string {
+	paths, err := filepath.Glob(filepath.Join(dir, "segment-*.wal"))
+	Expect(err).NotTo(HaveOccurred())
+	sort.Strings(paths)
+	return paths
+}
+
+func snapshotPaths(dir string) []string {
+	paths, err := filepath.Glob(filepath.Join(dir, "snapshot-*.json"))
+	Expect(err).NotTo(HaveOccurred())
+	return paths
+}
+
+// lastNonEmptySegment is the segment the last record was appended to, appends always start in a new segment on open
+func lastNonEmptySegment(dir string) string {
+	paths := segmentPaths(dir)
+	for i := len(paths) - 1; i >= 0; i-- {
+		info, err := os.Stat(paths[i])
+		Expect(err).NotTo(HaveOccurred())
+		if info.Size() > 0 {
+			return paths[i]
+		}
+	}
+	Fail("no segment has any records")
+	return ""
+}
+
+var _ = Describe("action-averager write-ahead log tests", func() {
+	var dir string
+	var durable *wal.DurableActionAverage
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "wal")
+		Expect(err).NotTo(HaveOccurred())
+		durable, err = wal.Open(dir, wal.SyncAlways, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(durable.Close()).To(Succeed())
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	reopen := func() {
+		Expect(durable.Close()).To(Succeed())
+		var err error
+		durable, err = wal.Open(dir, wal.SyncAlways, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("should keep its stats after it is reopened", func() {
+		addMultipleActions(durable, walActions[:2], !delay)
+		Expect(durable.AddSample("run", 70)).To(Succeed())
+		reopen()
+		verifyStats(durable.GetStats(), walExpStats)
+
+		// NOTE: reopening twice checks the records appended after the first reopen are replayed as well
+		Expect(durable.AddSample("run", 60)).To(Succeed())
+		reopen()
+		Expect(durable.GetStats()).To(ContainSubstring(`{"action":"run","avg":60,"count":3,`))
+	})
+
+	It("should not log rejected actions", func() {
+		err := durable.AddAction(`{"action":"run","time":-1}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal(`negative time value for input {"action":"run","time":-1}, rejecting`))
+		reopen()
+		Expect(durable.GetStats()).To(Equal(emptyStats))
+	})
+
+	It("should replay a compacted snapshot and the segments after it", func() {
+		addMultipleActions(durable, walActions[:2], !delay)
+		Expect(durable.Compact()).To(Succeed())
+		Expect(snapshotPaths(dir)).To(HaveLen(1))
+		Expect(segmentPaths(dir)).To(HaveLen(1))
+
+		Expect(durable.AddAction(walActions[2])).To(Succeed())
+		reopen()
+		verifyStats(durable.GetStats(), walExpStats)
+	})
+
+	It("should not replay segments a snapshot covers that were left by a crash during compaction", func() {
+		Expect(durable.AddSample("jump", 10)).To(Succeed())
+		Expect(durable.AddSample("jump", 20)).To(Succeed())
+		oldSegments := make(map[string][]byte)
+		for _, path := range segmentPaths(dir) {
+			data, err := ioutil.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			oldSegments[path] = data
+		}
+		Expect(durable.Compact()).To(Succeed())
+		Expect(durable.Close()).To(Succeed())
+
+		// NOTE: putting the old segments back is the same as crashing before compaction removed them
+		for path, data := range oldSegments {
+			Expect(ioutil.WriteFile(path, data, 0644)).To(Succeed())
+		}
+		var err error
+		durable, err = wal.Open(dir, wal.SyncAlways, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(durable.GetStats()).To(HavePrefix(`[{"action":"jump","avg":15,"count":2,"sum":30,`))
+		for path := range oldSegments {
+			Expect(path).NotTo(BeAnExistingFile())
+		}
+
+		// NOTE: a second compaction replaces the first snapshot
+		Expect(durable.AddSample("jump", 30)).To(Succeed())
+		Expect(durable.Compact()).To(Succeed())
+		Expect(snapshotPaths(dir)).To(HaveLen(1))
+		reopen()
+		Expect(durable.GetStats()).To(HavePrefix(`[{"action":"jump","avg":20,"count":3,"sum":60,`))
+	})
+
+	It("should keep accepting actions after a failed compaction", func() {
+		Expect(durable.AddAction(walActions[0])).To(Succeed())
+		// NOTE: a non empty directory where the snapshot goes fails renaming the snapshot into place
+		snapshotPath := filepath.Join(dir, "snapshot-0000000000000001.json")
+		Expect(os.MkdirAll(filepath.Join(snapshotPath, "blocked"), 0755)).To(Succeed())
+		Expect(durable.Compact()).NotTo(Succeed())
+		Expect(snapshotPath + ".tmp").NotTo(BeAnExistingFile())
+
+		Expect(os.RemoveAll(snapshotPath)).To(Succeed())
+		addMultipleActions(durable, walActions[1:], !delay)
+		Expect(durable.Compact()).To(Succeed())
+		reopen()
+		verifyStats(durable.GetStats(), walExpStats)
+	})
+
+	It("should remove temporary snapshots left by a crash during compaction", func() {
+		addMultipleActions(durable, walActions, !delay)
+		tmpPath := filepath.Join(dir, "snapshot-0000000000000001.json.tmp")
+		Expect(ioutil.WriteFile(tmpPath, []byte(`{"version":1,`), 0644)).To(Succeed())
+		reopen()
+		Expect(tmpPath).NotTo(BeAnExistingFile())
+		verifyStats(durable.GetStats(), walExpStats)
+	})
+
+	It("should rotate segments once they are over the max size", func() {
+		Expect(durable.Close()).To(Succeed())
+		var err error
+		durable, err = wal.Open(dir, wal.SyncNever, 0, 1)
+		Expect(err).NotTo(HaveOccurred())
+		addMultipleActions(durable, walActions, !delay)
+		Expect(len(segmentPaths(dir))).To(BeNumerically(">", len(walActions)))
+
+		reopen()
+		verifyStats(durable.GetStats(), walExpStats)
+	})
+
+	It("should not add an action in memory that was not logged", func() {
+		Expect(durable.Close()).To(Succeed())
+		var err error
+		durable, err = wal.Open(dir, wal.SyncAlways, 0, 1)
+		Expect(err).NotTo(HaveOccurred())
+
+		// NOTE: removing the directory fails the rotation after the first record, which is logged, so only the
+		// second record fails
+		Expect(os.RemoveAll(dir)).To(Succeed())
+		Expect(durable.AddAction(walActions[0])).To(Succeed())
+		Expect(durable.AddAction(walActions[1])).NotTo(Succeed())
+		Expect(durable.AddSample("jump", 10)).NotTo(Succeed())
+		Expect(durable.GetStats()).To(HavePrefix(`[{"action":"run","avg":50,"count":1,`))
+		Expect(durable.GetStats()).NotTo(ContainSubstring("jump"))
+		Expect(durable.Close()).NotTo(Succeed())
+		durable, err = wal.Open(dir, wal.SyncAlways, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should drop a partially written record at the end of the last segment", func() {
+		addMultipleActions(durable, walActions, !delay)
+		Expect(durable.AddAction(`{"action":"skip","time":5}`)).To(Succeed())
+		Expect(durable.Close()).To(Succeed())
+
+		path := lastNonEmptySegment(dir)
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Truncate(path, info.Size()-3)).To(Succeed())
+
+		durable, err = wal.Open(dir, wal.SyncAlways, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		verifyStats(durable.GetStats(), walExpStats)
+
+		// NOTE: the dropped record is truncated away, so later records are not hidden behind it
+		Expect(durable.AddAction(`{"action":"skip","time":5}`)).To(Succeed())
+		reopen()
+		Expect(durable.GetStats()).To(ContainSubstring(`{"action":"skip","avg":5,"count":1,`))
+	})
+
+	It("should drop a corrupted record at the end of the last segment", func() {
+		addMultipleActions(durable, walActions, !delay)
+		Expect(durable.AddAction(`{"action":"skip","time":5}`)).To(Succeed())
+		Expect(durable.Close()).To(Succeed())
+
+		path := lastNonEmptySegment(dir)
+		data, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		data[len(data)-2] ^= 0xff
+		Expect(ioutil.WriteFile(path, data, 0644)).To(Succeed())
+
+		durable, err = wal.Open(dir, wal.SyncAlways, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		verifyStats(durable.GetStats(), walExpStats)
+	})
+
+	It("should fail to open a log corrupted before its last segment", func() {
+		addMultipleActions(durable, walActions, !delay)
+		reopen()
+		Expect(durable.AddAction(walActions[0])).To(Succeed())
+		Expect(durable.Close()).To(Succeed())
+
+		path := segmentPaths(dir)[0]
+		data, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		data[len(data)-2] ^= 0xff
+		Expect(ioutil.WriteFile(path, data, 0644)).To(Succeed())
+
+		_, err = wal.Open(dir, wal.SyncAlways, 0, 0)
+		Expect(err).To(HaveOccurred())
+		durable, err = wal.Open(filepath.Join(dir, "empty"), wal.SyncAlways, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should keep its stats with every sync policy", func() {
+		for _, policy := range []wal.SyncPolicy{wal.SyncAlways, wal.SyncInterval, wal.SyncNever} {
+			Expect(durable.Close()).To(Succeed())
+			Expect(os.RemoveAll(dir)).To(Succeed())
+			var err error
+			durable, err = wal.Open(dir, policy, walSyncInterval, 0)
+			Expect(err).NotTo(HaveOccurred())
+			addMultipleActions(durable, walActions, !delay)
+			reopen()
+			verifyStats(durable.GetStats(), walExpStats)
+		}
+	})
+
+	It("should fail to add after it is closed", func() {
+		Expect(durable.Close()).To(Succeed())
+		Expect(durable.AddAction(walActions[0])).To(MatchError(actionaverager.ErrClosed))
+		Expect(durable.AddSample("run", 10)).To(MatchError(actionaverager.ErrClosed))
+		Expect(durable.Close()).To(Succeed())
+	})
+
+	It("should fail to open with an invalid sync interval", func() {
+		_, err := wal.Open(dir, wal.SyncInterval, 0, 0)
+		Expect(err).To(HaveOccurred())
+	})
+})
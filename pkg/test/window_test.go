@@ -0,0 +1,117 @@
+package actionaverager_test
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+const (
+	window     = 5 * time.Minute
+	numBuckets = 5
+)
+
+// fakeClock is a Clock that only moves when it is advanced
+type fakeClock struct {
+	mux sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	return fc.now
+}
+
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	fc.now = fc.now.Add(d)
+}
+
+var _ = Describe("action-averager window tests", func() {
+	var clock *fakeClock
+	var averager actionaverager.ActionAverager
+	BeforeEach(func() {
+		var err error
+		clock = newFakeClock()
+		averager, err = actionaverager.NewWindowActionAverager(window, numBuckets, clock.Now)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should average actions within the window", func() {
+		actions := []string{
+			`{"action":"run","time":10}`,
+			`{"action":"run","time":30}`,
+		}
+		addMultipleActions(averager, actions, !delay)
+		clock.Advance(time.Minute)
+		err := averager.AddAction(`{"action":"run","time":50}`)
+		Expect(err).NotTo(HaveOccurred())
+
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(`[{"action":"run","avg":30,"count":3,"sum":90,"min":10,"max":50}]`))
+	})
+
+	It("should drop actions that have left the window", func() {
+		actions := []string{
+			`{"action":"run","time":1000}`,
+			`{"action":"jump","time":20}`,
+		}
+		addMultipleActions(averager, actions, !delay)
+		clock.Advance(3 * time.Minute)
+		err := averager.AddAction(`{"action":"run","time":10}`)
+		Expect(err).NotTo(HaveOccurred())
+
+		stats := averager.GetStats()
+		expStats := []string{
+			`{"action":"jump","avg":20,"count":1,"sum":20,"min":20,"max":20}`,
+			`{"action":"run","avg":505,"count":2,"sum":1010,"min":10,"max":1000}`,
+		}
+		verifyStats(stats, expStats)
+
+		clock.Advance(3 * time.Minute)
+		stats = averager.GetStats()
+		Expect(stats).To(Equal(`[{"action":"run","avg":10,"count":1,"sum":10,"min":10,"max":10}]`))
+
+		clock.Advance(window)
+		stats = averager.GetStats()
+		Expect(stats).To(Equal(emptyStats))
+	})
+
+	It("should reuse buckets once they have left the window", func() {
+		err := averager.AddAction(`{"action":"run","time":100}`)
+		Expect(err).NotTo(HaveOccurred())
+		clock.Advance(window)
+		err = averager.AddAction(`{"action":"run","time":20}`)
+		Expect(err).NotTo(HaveOccurred())
+
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(`[{"action":"run","avg":20,"count":1,"sum":20,"min":20,"max":20}]`))
+	})
+
+	It("should reject the same input as AddAction", func() {
+		err := averager.AddAction(`{"action":"bike","time":-1}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal(`negative time value for input {"action":"bike","time":-1}, rejecting`))
+		stats := averager.GetStats()
+		Expect(stats).To(Equal(emptyStats))
+	})
+
+	It("should fail to create an averager with an invalid window", func() {
+		_, err := actionaverager.NewWindowActionAverager(window, 0, clock.Now)
+		Expect(err).To(HaveOccurred())
+		_, err = actionaverager.NewWindowActionAverager(time.Duration(numBuckets-1), numBuckets, clock.Now)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,99 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+const (
+	// recordHeaderSize is the size of the length and checksum before every record payload
+	recordHeaderSize = 8
+	// maxRecordSize bounds the length read from a record header, so a corrupted length can not allocate forever
+	maxRecordSize = 16 * 1024 * 1024
+
+	// jsonRecord is a payload holding the input of AddAction
+	jsonRecord byte = 1
+	// sampleRecord is a payload holding the time bits and action of AddSample
+	sampleRecord byte = 2
+	sampleSize        = 8
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errCorruptRecord is returned for a record that is truncated or does not match its checksum
+var errCorruptRecord = errors.New("corrupt record")
+
+// record is a single logged add, exactly one of input or actStr is used depending on kind
+type record struct {
+	kind    byte
+	input   string
+	actStr  string
+	timeFlt float64
+}
+
+// encode returns the record framed as length, checksum and payload, all integers are little endian
+func (rec *record) encode() []byte {
+	var payload []byte
+	switch rec.kind {
+	case jsonRecord:
+		payload = make([]byte, 1+len(rec.input))
+		copy(payload[1:], rec.input)
+	case sampleRecord:
+		payload = make([]byte, 1+sampleSize+len(rec.actStr))
+		binary.LittleEndian.PutUint64(payload[1:], math.Float64bits(rec.timeFlt))
+		copy(payload[1+sampleSize:], rec.actStr)
+	}
+	payload[0] = rec.kind
+
+	frame := make([]byte, recordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:], crc32.Checksum(payload, crcTable))
+	copy(frame[recordHeaderSize:], payload)
+	return frame
+}
+
+// readRecord reads the next record from reader, it returns io.EOF at a clean end and errCorruptRecord for a record
+// that is partially written or corrupted, along with the number of bytes read
+func readRecord(reader io.Reader) (*record, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	n, err := io.ReadFull(reader, header)
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	if err != nil {
+		return nil, int64(n), errCorruptRecord
+	}
+
+	length := binary.LittleEndian.Uint32(header)
+	if length == 0 || length > maxRecordSize {
+		return nil, int64(n), errCorruptRecord
+	}
+	payload := make([]byte, length)
+	m, err := io.ReadFull(reader, payload)
+	read := int64(n + m)
+	if err != nil {
+		return nil, read, errCorruptRecord
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, read, errCorruptRecord
+	}
+
+	rec := &record{kind: payload[0]}
+	switch rec.kind {
+	case jsonRecord:
+		rec.input = string(payload[1:])
+	case sampleRecord:
+		if len(payload) < 1+sampleSize {
+			return nil, read, errCorruptRecord
+		}
+		rec.timeFlt = math.Float64frombits(binary.LittleEndian.Uint64(payload[1:]))
+		rec.actStr = string(payload[1+sampleSize:])
+	default:
+		return nil, read, fmt.Errorf("%w, unknown record kind %d", errCorruptRecord, rec.kind)
+	}
+	return rec, read, nil
+}
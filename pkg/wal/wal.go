@@ -0,0 +1,531 @@
+package wal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/action-averager/pkg/actionaverager"
+)
+
+// SyncPolicy is when appended records are synced to disk
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs after every record, an accepted action is never lost
+	SyncAlways SyncPolicy = iota
+	// SyncInterval syncs once every sync interval, actions accepted within the last interval can be lost
+	SyncInterval
+	// SyncNever leaves syncing to the operating system, actions are only lost if the operating system crashes
+	SyncNever
+)
+
+const (
+	// DefaultMaxSegmentSize is the segment size used when the max segment size given is not greater than 0
+	DefaultMaxSegmentSize = 64 * 1024 * 1024
+
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".json"
+	snapshotFormat = snapshotPrefix + "%016d" + snapshotSuffix
+	segmentPrefix  = "segment-"
+	segmentSuffix  = ".wal"
+	segmentFormat  = segmentPrefix + "%016d" + segmentSuffix
+	tmpSuffix      = ".tmp"
+
+	filePerm = 0644
+	dirPerm  = 0755
+)
+
+// DurableActionAverage is an ActionAverage that appends every accepted action to a segmented write-ahead log, so
+// its state survives a restart or crash. Actions are validated before they are appended, so that only actions the
+// averager accepts are logged, and added in memory after, so that memory never has an action its log does not. If
+// appending fails the averager stops accepting actions, since the log may have a partial record.
+type DurableActionAverage struct {
+	mux            sync.Mutex
+	averager       *actionaverager.ActionAverage
+	dir            string
+	policy         SyncPolicy
+	maxSegmentSize int64
+	segment        *os.File
+	segmentNum     uint64
+	segmentSize    int64
+	failErr        error
+	closed         bool
+	done           chan struct{}
+	syncDone       chan struct{}
+}
+
+// Open opens the log in dir, creating dir if needed, and replays the snapshot and every segment into a new
+// ActionAverage. A partially written or corrupted record at the end of the last segment is from a crash while
+// appending, so it is truncated away, corruption anywhere else is returned as an error. syncInterval is only used
+// with SyncInterval.
+func Open(dir string, policy SyncPolicy, syncInterval time.Duration, maxSegmentSize int64) (*DurableActionAverage, error) {
+	if policy == SyncInterval && syncInterval <= 0 {
+		return nil, fmt.Errorf("sync interval, %s, must be greater than 0", syncInterval)
+	}
+	if maxSegmentSize <= 0 {
+		maxSegmentSize = DefaultMaxSegmentSize
+	}
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, err
+	}
+
+	dav := &DurableActionAverage{
+		averager:       actionaverager.NewActionAverager().(*actionaverager.ActionAverage),
+		dir:            dir,
+		policy:         policy,
+		maxSegmentSize: maxSegmentSize,
+	}
+	if err := dav.replay(); err != nil {
+		return nil, err
+	}
+	// NOTE: the last segment is synced by replay before a new segment is created, otherwise a crash could leave a
+	// torn record at the end of a segment that is no longer the last one
+	if err := dav.openSegment(dav.segmentNum + 1); err != nil {
+		return nil, err
+	}
+
+	if policy == SyncInterval {
+		dav.done = make(chan struct{})
+		dav.syncDone = make(chan struct{})
+		go dav.syncEvery(syncInterval)
+	}
+	return dav, nil
+}
+
+// AddAction adds a json serialized action like ActionAverage.AddAction and appends it to the log
+func (dav *DurableActionAverage) AddAction(input string) error {
+	dav.mux.Lock()
+	defer dav.mux.Unlock()
+
+	if err := dav.checkWritable(); err != nil {
+		return err
+	}
+	actStr, timeFlt, err := dav.averager.ParseAction(input)
+	if err != nil {
+		return err
+	}
+	if err := dav.append(&record{kind: jsonRecord, input: input}); err != nil {
+		return err
+	}
+	// NOTE: the action was already validated, so adding it can not fail
+	return dav.averager.AddSample(actStr, timeFlt)
+}
+
+// AddSample adds an action and time like ActionAverage.AddSample and appends it to the log
+func (dav *DurableActionAverage) AddSample(actStr string, timeFlt float64) error {
+	dav.mux.Lock()
+	defer dav.mux.Unlock()
+
+	if err := dav.checkWritable(); err != nil {
+		return err
+	}
+	if err := dav.averager.ValidateSample(actStr, timeFlt); err != nil {
+		return err
+	}
+	if err := dav.append(&record{kind: sampleRecord, actStr: actStr, timeFlt: timeFlt}); err != nil {
+		return err
+	}
+	return dav.averager.AddSample(actStr, timeFlt)
+}
+
+// GetStats returns the stats of the ActionAverage
+func (dav *DurableActionAverage) GetStats() string {
+	return dav.averager.GetStats()
+}
+
+// Averager returns the ActionAverage the log is replayed into, for reading its stats. Adding to it directly skips
+// the log.
+func (dav *DurableActionAverage) Averager() *actionaverager.ActionAverage {
+	return dav.averager
+}
+
+// Compact writes a snapshot of the current state and removes every segment it covers, so replay only has to read
+// the snapshot and the segments after it. The snapshot is named after the last segment it covers, so segments that
+// were not removed before a crash are skipped by replay instead of being added twice.
+func (dav *DurableActionAverage) Compact() error {
+	dav.mux.Lock()
+	defer dav.mux.Unlock()
+
+	if err := dav.checkWritable(); err != nil {
+		return err
+	}
+
+	// NOTE: the lock is held for the whole compaction, so the snapshot has exactly the records of the segments
+	// up to and including the current one
+	if err := dav.segment.Sync(); err != nil {
+		return dav.fail(err)
+	}
+	lastNum := dav.segmentNum
+	// NOTE: the log is not changed until the snapshot is written, so a failed snapshot does not stop later appends
+	if err := dav.writeSnapshot(lastNum); err != nil {
+		return err
+	}
+	if err := dav.segment.Close(); err != nil {
+		return dav.fail(err)
+	}
+	if err := dav.openSegment(lastNum + 1); err != nil {
+		return dav.fail(err)
+	}
+	return dav.removeCovered(lastNum)
+}
+
+// Close syncs and closes the log, the averager can not be added to after it is closed
+func (dav *DurableActionAverage) Close() error {
+	dav.mux.Lock()
+	if dav.closed {
+		dav.mux.Unlock()
+		return nil
+	}
+	dav.closed = true
+	dav.mux.Unlock()
+
+	// NOTE: the sync goroutine locks as well, so it is stopped without holding the lock
+	if dav.done != nil {
+		close(dav.done)
+		<-dav.syncDone
+	}
+
+	dav.mux.Lock()
+	defer dav.mux.Unlock()
+
+	syncErr := dav.segment.Sync()
+	if err := dav.segment.Close(); err != nil {
+		return err
+	}
+	return syncErr
+}
+
+func (dav *DurableActionAverage) checkWritable() error {
+	if dav.closed {
+		return fmt.Errorf("write-ahead log: %w", actionaverager.ErrClosed)
+	}
+	if dav.failErr != nil {
+		return fmt.Errorf("write-ahead log failed, %v", dav.failErr)
+	}
+	return nil
+}
+
+func (dav *DurableActionAverage) fail(err error) error {
+	dav.failErr = err
+	return err
+}
+
+// append writes a record to the current segment, rotating to a new segment once it is over the max size, the
+// lock must be held by the caller
+func (dav *DurableActionAverage) append(rec *record) error {
+	frame := rec.encode()
+	if _, err := dav.segment.Write(frame); err != nil {
+		return dav.fail(err)
+	}
+	dav.segmentSize += int64(len(frame))
+
+	if dav.policy == SyncAlways {
+		if err := dav.segment.Sync(); err != nil {
+			return dav.fail(err)
+		}
+	}
+
+	// NOTE: the record is already logged, so a failed rotation stops later appends but does not fail this one
+	if dav.segmentSize >= dav.maxSegmentSize {
+		if err := dav.rotate(); err != nil {
+			dav.fail(err)
+		}
+	}
+	return nil
+}
+
+func (dav *DurableActionAverage) rotate() error {
+	if err := dav.segment.Sync(); err != nil {
+		return err
+	}
+	if err := dav.segment.Close(); err != nil {
+		return err
+	}
+	return dav.openSegment(dav.segmentNum + 1)
+}
+
+func (dav *DurableActionAverage) syncEvery(interval time.Duration) {
+	defer close(dav.syncDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dav.done:
+			return
+		case <-ticker.C:
+			dav.mux.Lock()
+			if dav.failErr == nil {
+				if err := dav.segment.Sync(); err != nil {
+					dav.fail(err)
+				}
+			}
+			dav.mux.Unlock()
+		}
+	}
+}
+
+// replay restores the latest snapshot and then adds every record of every segment after it in order, segments the
+// snapshot covers and temporary snapshots are left over from a crash during compaction so they are removed instead
+func (dav *DurableActionAverage) replay() error {
+	tmpNums, err := dav.fileNums(snapshotPrefix, snapshotSuffix+tmpSuffix)
+	if err != nil {
+		return err
+	}
+	for _, num := range tmpNums {
+		if err := os.Remove(dav.snapshotPath(num) + tmpSuffix); err != nil {
+			return err
+		}
+	}
+
+	snapshotNums, err := dav.fileNums(snapshotPrefix, snapshotSuffix)
+	if err != nil {
+		return err
+	}
+	var snapshotNum uint64
+	if len(snapshotNums) > 0 {
+		snapshotNum = snapshotNums[len(snapshotNums)-1]
+		if err := dav.restoreSnapshot(snapshotNum); err != nil {
+			return err
+		}
+		// NOTE: the snapshot may cover every segment, so new segments are numbered after it either way
+		dav.segmentNum = snapshotNum
+	}
+
+	nums, err := dav.fileNums(segmentPrefix, segmentSuffix)
+	if err != nil {
+		return err
+	}
+	for i, num := range nums {
+		if num <= snapshotNum {
+			continue
+		}
+		if err := dav.replaySegment(num, i == len(nums)-1); err != nil {
+			return err
+		}
+		dav.segmentNum = num
+	}
+
+	if len(snapshotNums) > 0 {
+		return dav.removeCovered(snapshotNum)
+	}
+	return nil
+}
+
+func (dav *DurableActionAverage) restoreSnapshot(num uint64) error {
+	snapshot, err := os.Open(dav.snapshotPath(num))
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	if err := dav.averager.Restore(bufio.NewReader(snapshot)); err != nil {
+		return fmt.Errorf("unable to restore snapshot, %v", err)
+	}
+	return nil
+}
+
+// removeCovered removes every segment covered by the snapshot of segment num and every older snapshot
+func (dav *DurableActionAverage) removeCovered(num uint64) error {
+	segmentNums, err := dav.fileNums(segmentPrefix, segmentSuffix)
+	if err != nil {
+		return err
+	}
+	for _, segmentNum := range segmentNums {
+		if segmentNum > num {
+			break
+		}
+		if err := os.Remove(dav.segmentPath(segmentNum)); err != nil {
+			return err
+		}
+	}
+
+	snapshotNums, err := dav.fileNums(snapshotPrefix, snapshotSuffix)
+	if err != nil {
+		return err
+	}
+	for _, snapshotNum := range snapshotNums {
+		if snapshotNum >= num {
+			break
+		}
+		if err := os.Remove(dav.snapshotPath(snapshotNum)); err != nil {
+			return err
+		}
+	}
+	return syncDir(dav.dir)
+}
+
+func (dav *DurableActionAverage) replaySegment(num uint64, isLast bool) error {
+	path := dav.segmentPath(num)
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		rec, n, err := readRecord(reader)
+		if err == io.EOF {
+			if isLast {
+				// NOTE: the records of the last segment may only be in the page cache after a crash, they have
+				// to be on disk before records are appended to a new segment
+				return syncFile(path)
+			}
+			return nil
+		}
+		if errors.Is(err, errCorruptRecord) {
+			if !isLast {
+				return fmt.Errorf("%v at offset %d of segment %s, which is not the last segment", err, offset, path)
+			}
+			// NOTE: a bad record at the end of the last segment is an append that was interrupted by a crash,
+			// it was never acknowledged so it is truncated away
+			return truncateFile(path, offset)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := dav.apply(rec); err != nil {
+			return fmt.Errorf("unable to replay record at offset %d of segment %s, %v", offset, path, err)
+		}
+		offset += n
+	}
+}
+
+func (dav *DurableActionAverage) apply(rec *record) error {
+	if rec.kind == sampleRecord {
+		return dav.averager.AddSample(rec.actStr, rec.timeFlt)
+	}
+	return dav.averager.AddAction(rec.input)
+}
+
+func (dav *DurableActionAverage) openSegment(num uint64) error {
+	file, err := os.OpenFile(dav.segmentPath(num), os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		return err
+	}
+	// NOTE: sync the directory so the new segment itself survives a crash
+	if err := syncDir(dav.dir); err != nil {
+		file.Close()
+		return err
+	}
+	dav.segment = file
+	dav.segmentNum = num
+	dav.segmentSize = 0
+	return nil
+}
+
+// writeSnapshot writes the snapshot that covers every segment up to and including segment num to a temporary file
+// and renames it into place, so a crash while writing never leaves a partial snapshot. The temporary file is
+// removed if writing fails.
+func (dav *DurableActionAverage) writeSnapshot(num uint64) error {
+	path := dav.snapshotPath(num)
+	tmpPath := path + tmpSuffix
+	err := writeFile(tmpPath, dav.averager.Snapshot)
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		// WARNING: suppressing error, since the write error is the one to return and replay removes it anyway
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return syncDir(dav.dir)
+}
+
+// writeFile creates or truncates the file at path, writes it with write and syncs it
+func writeFile(path string, write func(io.Writer) error) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerm)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	err = write(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// fileNums returns the number of every file in dir named with prefix and suffix, like segments or snapshots, in
+// ascending order
+func (dav *DurableActionAverage) fileNums(prefix string, suffix string) ([]uint64, error) {
+	entries, err := os.ReadDir(dav.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var nums []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		num, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		nums = append(nums, num)
+	}
+	sort.Slice(nums, func(i, j int) bool {
+		return nums[i] < nums[j]
+	})
+	return nums, nil
+}
+
+func (dav *DurableActionAverage) segmentPath(num uint64) string {
+	return filepath.Join(dav.dir, fmt.Sprintf(segmentFormat, num))
+}
+
+func (dav *DurableActionAverage) snapshotPath(num uint64) string {
+	return filepath.Join(dav.dir, fmt.Sprintf(snapshotFormat, num))
+}
+
+func syncFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, filePerm)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+func truncateFile(path string, size int64) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, filePerm)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := file.Truncate(size); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}